@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	// Gin is a web framework for Go (handling HTTP requests/responses)
 	"github.com/gin-gonic/gin"
@@ -14,14 +19,25 @@ import (
 	// GORM is an Object Relational Mapper (ORM) for database interactions
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
 	// Internal packages from our own project
 	"time"
 
 	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/config"
 	"github.com/ristep/smanzy_backend/internal/handlers"
+	"github.com/ristep/smanzy_backend/internal/jobs"
+	"github.com/ristep/smanzy_backend/internal/logging"
+	"github.com/ristep/smanzy_backend/internal/mediaproc"
+	"github.com/ristep/smanzy_backend/internal/metrics"
 	"github.com/ristep/smanzy_backend/internal/middleware"
-	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/migrate"
+	"github.com/ristep/smanzy_backend/internal/reconcile"
+	"github.com/ristep/smanzy_backend/internal/seed"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"github.com/ristep/smanzy_backend/internal/tracing"
 	"github.com/ulule/limiter/v3"
 	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
 	"github.com/ulule/limiter/v3/drivers/store/memory"
@@ -30,7 +46,8 @@ import (
 // main is the entry point of the application
 func main() {
 	// Parse CLI flags
-	migrate := flag.Bool("migrate", false, "Run database migrations")
+	runMigrations := flag.Bool("migrate", false, "Apply pending database migrations before starting")
+	configFile := flag.String("config", "", "YAML configuration file (default config.yml if it exists, or $CONFIG_FILE)")
 	flag.Parse()
 
 	// 1. Load environment variables from .env file (if it exists)
@@ -40,86 +57,222 @@ func main() {
 	}
 
 	// 2. Configuration Setup
-	// We read configuration (Database URL, Secrets, Port) from the environment
-	dbDSN := os.Getenv("DB_DSN") // Data Source Name (connection string)
-	if dbDSN == "" {
-		log.Fatal("DB_DSN environment variable is required")
+	// Defaults are overridden by the YAML file and then by environment
+	// variables (see internal/config)
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Structured logs (LOG_LEVEL, LOG_FORMAT); the standard log package
+	// writes through the same logger
+	appLogger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.LogFormat())
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
 	}
+	slog.SetDefault(appLogger)
+
+	// Subcommands manage the database and exit:
+	//   migrate up|down|status|create  versioned schema migrations
+	//   seed                           roles and the bootstrap admin
+	switch flag.Arg(0) {
+	case "migrate", "seed":
+		connect := func() (*gorm.DB, error) {
+			if cfg.Database.DSN == "" {
+				return nil, errors.New("DB_DSN environment variable is required")
+			}
+			return gorm.Open(postgres.Open(cfg.Database.DSN), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if flag.Arg(0) == "migrate" {
+			if err := migrate.Command(ctx, flag.Args()[1:], connect, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 
-	jwtSecret := os.Getenv("JWT_SECRET") // Secret key for signing JWT tokens
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
+		db, err := connect()
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		runSeed(ctx, db, cfg.Bootstrap, true)
+		return
 	}
 
-	serverPort := os.Getenv("SERVER_PORT") // Port to run the server on
-	if serverPort == "" {
-		serverPort = "8080" // Default to 8080 if not specified
+	// The API needs a complete configuration; all problems are reported at once
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
 	}
 
 	// 3. Database Connection
 	// Connect to PostgreSQL using GORM
-	db, err := gorm.Open(postgres.Open(dbDSN), &gorm.Config{})
+	// Slow queries and database errors are logged like everything else,
+	// with the request ID when the query has the request context
+	db, err := gorm.Open(postgres.Open(cfg.Database.DSN), &gorm.Config{
+		Logger: logger.NewSlogLogger(appLogger, logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+			ParameterizedQueries:      true,
+		}),
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Tracing: W3C traceparent propagation always, spans exported over
+	// OTLP/HTTP when TRACING_ENABLED is set. Queries run with a request
+	// context become child spans of the request.
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Enabled:     cfg.Tracing.Enabled,
+		Endpoint:    cfg.Tracing.Endpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if err := db.Use(tracing.NewPlugin()); err != nil {
+		log.Fatalf("Failed to set up database tracing: %v", err)
+	}
+
 	// 4. Database Migration
-	// flagged migration, if specified, flag
-	// the Go structs defined in `internal/models`.
-	// Be careful with this in production!
-	if *migrate {
-		if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Media{}, &models.Album{}); err != nil {
-			log.Fatalf("Failed to auto-migrate models: %v", err)
+	// With -migrate, the versioned SQL migrations embedded from
+	// internal/migrate/migrations are applied (same as "migrate up")
+	migrator, err := migrate.New(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	if *runMigrations {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
 		}
+		log.Printf("Database migration completed successfully (%d applied)", len(applied))
 	}
 
-	log.Println("Database migration completed successfully")
-
 	// 5. Seeding Data
-	// Ensure that basic roles exist in the database
-	db.FirstOrCreate(&models.Role{}, models.Role{Name: "user"})
-	db.FirstOrCreate(&models.Role{}, models.Role{Name: "admin"})
+	// Ensure that basic roles and the bootstrap admin exist in the database
+	runSeed(context.Background(), db, cfg.Bootstrap, false)
+
+	// SIGINT and SIGTERM (e.g. from a deploy) start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// 6. Service Initialization
 	// Initialize our services and handlers, injecting dependencies (like the DB connection)
-	jwtService := auth.NewJWTService(jwtSecret)
+	jwtService := auth.NewJWTService(cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+
+	// Signed media URLs fall back to the JWT secret when no dedicated secret is set
+	mediaURLSecret := cfg.Media.URLSecret
+	if mediaURLSecret == "" {
+		mediaURLSecret = cfg.Auth.JWTSecret
+	}
+	urlSigner, err := auth.NewURLSigner(mediaURLSecret, cfg.Media.URLMode, cfg.Media.RequireSignedURLs)
+	if err != nil {
+		log.Fatalf("Invalid media URL signing configuration: %v", err)
+	}
+
+	// Uploaded files are stored on the local filesystem
+	store := storage.NewLocal(cfg.Storage.UploadsDir)
+
+	// Background probing of uploaded audio and video (ffprobe/ffmpeg)
+	tools := mediaproc.Tools{FFprobe: cfg.Media.FFprobePath, FFmpeg: cfg.Media.FFmpegPath}
+	// Background jobs are stored in the database and run by a worker pool.
+	// JOB_WORKERS=0 disables the pool, e.g. when a separate instance runs jobs.
+	jobQueue := jobs.NewQueue(db)
+	processor := mediaproc.NewProcessor(db, store, jobQueue, tools)
+
+	// Workers are stopped separately from the server so that jobs can be
+	// interrupted while in-flight requests drain
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workersDone := make(chan struct{})
+	if cfg.Jobs.Workers > 0 {
+		worker := jobs.NewWorker(db, cfg.Jobs.Workers)
+		processor.Register(worker)
+
+		// Media and albums in the trash are purged after
+		// MEDIA_TRASH_RETENTION_DAYS (default 30, 0 keeps them until purged
+		// explicitly)
+		if cfg.Media.TrashRetentionDays > 0 {
+			retention := time.Duration(cfg.Media.TrashRetentionDays) * 24 * time.Hour
+			services.NewTrashService(db, store).Register(worker, retention)
+			services.NewAlbumService(db).Register(worker, retention)
+			worker.Every(time.Hour, services.JobPurgeTrash)
+			worker.Every(time.Hour, services.JobPurgeAlbums)
+		}
+
+		// Optional periodic comparison of the uploads directory with the
+		// database (see cmd/reconcile for one-off runs)
+		if cfg.Jobs.ReconcileInterval > 0 {
+			reconciler := reconcile.NewReconciler(db, store)
+			reconciler.Register(worker, reconcile.Options{Fix: cfg.Jobs.ReconcileFix})
+			worker.Every(cfg.Jobs.ReconcileInterval, reconcile.JobReconcile)
+		}
+
+		go func() {
+			defer close(workersDone)
+			worker.Run(workerCtx)
+		}()
+	} else {
+		close(workersDone)
+	}
 
 	authHandler := handlers.NewAuthHandler(db, jwtService)
 	userHandler := handlers.NewUserHandler(db)
-	mediaHandler := handlers.NewMediaHandler(db)
+	// Replaced files are kept as restorable versions (MEDIA_MAX_VERSIONS, 0 disables versioning)
+	mediaHandler := handlers.NewMediaHandler(db, store, processor, urlSigner, cfg.Media.StripGPS, cfg.Media.MaxVersions)
 	albumHandler := handlers.NewAlbumHandler(db)
+	jobHandler := handlers.NewJobHandler(jobQueue)
+	searchHandler := handlers.NewSearchHandler(db)
+	tagHandler := handlers.NewTagHandler(db)
+	// Readiness requires HEALTH_MIN_FREE_MB free in the uploads directory
+	healthHandler := handlers.NewHealthHandler(db, store, migrator, cfg.Health.Timeout, uint64(cfg.Health.MinFreeMB)<<20)
 
 	// 7. Router Setup
-	// Create a new Gin router with default middleware (logger and recovery)
-	router := gin.Default()
-
-	// Custom handler for rate limit errors
-	router.Use(func(c *gin.Context) {
-		c.Next()
-		if c.Writer.Status() == http.StatusTooManyRequests {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded. Try again later."})
-		}
-	})
+	// Every request gets an ID (X-Request-ID) that appears in its logs and
+	// error responses, and a server span continuing the caller's trace.
+	// Panics are logged, recorded on the span and answered with a 500.
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware(), middleware.TracingMiddleware())
+
+	// Count and time every request by route (served on /metrics), including
+	// those that panic, so this runs before recovery
+	router.Use(middleware.MetricsMiddleware())
+
+	router.Use(middleware.LoggerMiddleware(), middleware.RecoveryMiddleware())
 
 	// Apply CORS middleware (Cross-Origin Resource Sharing) to allow frontend to talk to backend
 	router.Use(middleware.CORSMiddleware())
 
-	// Health check endpoint - useful for monitoring if the app is up
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Health check endpoints for load balancers and orchestrators
+	router.GET("/health", healthHandler.LiveHandler)        // Kept for existing monitors
+	router.GET("/health/live", healthHandler.LiveHandler)   // Process is up
+	router.GET("/health/ready", healthHandler.ReadyHandler) // Database, storage and schema are usable
+
+	// Prometheus metrics, behind a bearer token when METRICS_TOKEN is set
+	if sqlDB, err := db.DB(); err == nil {
+		metrics.Register(sqlDB, jobQueue)
+	}
+	router.GET("/metrics", middleware.MetricsAuthMiddleware(cfg.Metrics.Token), gin.WrapH(metrics.Handler()))
 
-	// Initialize rate limiter (e.g., 5 requests per minute per IP)
+	// Initialize rate limiter (AUTH_RATE_LIMIT requests per AUTH_RATE_PERIOD per IP)
 	rate := limiter.Rate{
-		Period: time.Minute,
-		Limit:  15, // Adjust this value as needed
+		Period: cfg.RateLimit.Period,
+		Limit:  int64(cfg.RateLimit.Requests),
 	}
-	store := memory.NewStore() // Use in-memory for dev; switch to Redis for production
-	limiterInstance := limiter.New(store, rate)
-	rateLimitMiddleware := mgin.NewMiddleware(limiterInstance)
+	limiterStore := memory.NewStore() // Use in-memory for dev; switch to Redis for production
+	limiterInstance := limiter.New(limiterStore, rate)
+	rateLimitMiddleware := mgin.NewMiddleware(limiterInstance, mgin.WithLimitReachedHandler(middleware.RateLimitReachedHandler))
 
 	// 8. Define Routes
 	// Group routes under /api
+	authMiddleware := middleware.AuthMiddleware(jwtService, db)
 	api := router.Group("/api")
 	{
 		// == PUBLIC ROUTES ==
@@ -139,19 +292,23 @@ func main() {
 		// Serve uploaded files directly (for development)
 		// :name is a path parameter that captures the filename
 		api.GET("/media/files/:name", mediaHandler.ServeFileHandler)
+
+		// HLS playlists and segments, with a token or a signed link
+		api.GET("/media/:id/hls/*path", middleware.SignedURLOrAuthMiddleware(urlSigner, authMiddleware), mediaHandler.ServeHLSHandler)
 	}
 
 	// == PROTECTED ROUTES ==
 	// Requires a valid JWT token in the Authorization header
 	protectedAPI := router.Group("/api")
 	// Apply the AuthMiddleware to check for the token
-	protectedAPI.Use(middleware.AuthMiddleware(jwtService, db))
+	protectedAPI.Use(authMiddleware)
 	{
 		// Authenticated User routes
 		profile := protectedAPI.Group("/profile")
 		{
-			profile.GET("", authHandler.ProfileHandler)       // Get current user profile
-			profile.PUT("", authHandler.UpdateProfileHandler) // Update current user profile
+			profile.GET("", authHandler.ProfileHandler)               // Get current user profile
+			profile.PUT("", authHandler.UpdateProfileHandler)         // Update current user profile
+			profile.GET("/media", mediaHandler.ListUserMediasHandler) // Your own uploads (filters as /api/media)
 		}
 
 		// Admin-only routes
@@ -169,14 +326,45 @@ func main() {
 			users.DELETE("/:id/roles", userHandler.RemoveRoleHandler)
 		}
 
+		// Background job administration (admin only)
+		jobRoutes := protectedAPI.Group("/jobs")
+		jobRoutes.Use(middleware.RoleMiddleware("admin"))
+		{
+			jobRoutes.GET("", jobHandler.ListJobsHandler)            // List jobs (filter by status/type)
+			jobRoutes.GET("/stats", jobHandler.JobStatsHandler)      // Job counts per status
+			jobRoutes.POST("/:id/retry", jobHandler.RetryJobHandler) // Requeue a dead job
+		}
+
+		// Full-text search over media and your albums
+		protectedAPI.GET("/search", searchHandler.FullTextSearchHandler)
+
 		// Media routes (authenticated)
 		media := protectedAPI.Group("/media")
 		{
 			media.POST("", mediaHandler.UploadHandler)                     // Upload a new file
 			media.GET("/:id", mediaHandler.GetMediaHandler)                // Get file content
 			media.GET("/:id/details", mediaHandler.GetMediaDetailsHandler) // Get file metadata
+			media.GET("/:id/url", mediaHandler.GetMediaURLHandler)         // Get a signed, expiring file URL
 			media.PUT("/:id", mediaHandler.UpdateMediaHandler)             // Edit file (Owner or Admin)
-			media.DELETE("/:id", mediaHandler.DeleteMediaHandler)          // Delete file (Owner or Admin)
+			media.GET("/:id/versions", mediaHandler.ListVersionsHandler)   // Previous files (Owner or Admin)
+			media.DELETE("/:id", mediaHandler.DeleteMediaHandler)          // Move to trash (Owner or Admin)
+			media.GET("/trash", mediaHandler.ListTrashHandler)             // Media in the trash
+			media.POST("/:id/restore", mediaHandler.RestoreMediaHandler)   // Move out of the trash
+			media.DELETE("/:id/purge", mediaHandler.PurgeMediaHandler)     // Delete permanently
+
+			// Tags (Owner or Admin)
+			media.POST("/:id/tags", mediaHandler.AddTagsHandler)
+			media.DELETE("/:id/tags/:tag", mediaHandler.RemoveTagHandler)
+
+			// Make a previous file current again (Owner or Admin)
+			media.POST("/:id/versions/:version/restore", mediaHandler.RestoreVersionHandler)
+		}
+
+		// Tag routes (authenticated)
+		tags := protectedAPI.Group("/tags")
+		{
+			tags.GET("", tagHandler.ListUserTagsHandler)                  // Tags on your media with counts
+			tags.GET("/autocomplete", tagHandler.AutocompleteTagsHandler) // Tags starting with ?q=
 		}
 
 		// Album routes (authenticated)
@@ -188,6 +376,11 @@ func main() {
 			albums.PUT("/:id", albumHandler.UpdateAlbumHandler)    // Update album details
 			albums.DELETE("/:id", albumHandler.DeleteAlbumHandler) // Delete album (soft delete)
 
+			// Album trash
+			albums.GET("/trash", albumHandler.GetDeletedAlbumsHandler)                  // Albums in the trash
+			albums.POST("/:id/restore", albumHandler.RestoreAlbumHandler)               // Undo soft delete (Owner or Admin)
+			albums.DELETE("/:id/permanent", albumHandler.PermanentlyDeleteAlbumHandler) // Delete for good (Owner or Admin)
+
 			// Album media management
 			albums.POST("/:id/media", albumHandler.AddMediaToAlbumHandler)        // Add media to album
 			albums.DELETE("/:id/media", albumHandler.RemoveMediaFromAlbumHandler) // Remove media from album
@@ -196,9 +389,88 @@ func main() {
 	}
 
 	// 9. Start Server
-	addr := fmt.Sprintf(":%s", serverPort)
-	log.Printf("Starting server on %s", addr)
-	if err := router.Run(addr); err != nil {
+	// Timeouts keep slow or idle clients from holding connections forever
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", srv.Addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+
+	// 10. Graceful Shutdown
+	// New connections are refused while in-flight requests get up to
+	// SERVER_SHUTDOWN_TIMEOUT to finish. Running jobs are interrupted and go
+	// back to the queue. A second signal exits immediately.
+	stop()
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	stopWorkers()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: in-flight requests did not finish: %v", err)
+		srv.Close()
+	}
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		log.Println("Warning: background jobs did not stop in time")
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Warning: failed to flush traces: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	log.Println("Server stopped")
+}
+
+// runSeed creates missing roles and the bootstrap admin configured with
+// BOOTSTRAP_ADMIN_EMAIL, BOOTSTRAP_ADMIN_PASSWORD and BOOTSTRAP_ADMIN_NAME.
+// Without a password, the admin is only created from the interactive seed
+// command, which generates one and prints it to stdout, never to the logs.
+func runSeed(ctx context.Context, db *gorm.DB, bootstrap config.Bootstrap, interactive bool) {
+	result, err := seed.Run(ctx, db, seed.Options{
+		Admin: seed.Admin{
+			Email:    bootstrap.AdminEmail,
+			Password: bootstrap.AdminPassword,
+			Name:     bootstrap.AdminName,
+		},
+		GeneratePassword: interactive,
+	})
+	if err != nil {
+		log.Fatalf("Failed to seed database: %v", err)
+	}
+
+	for _, role := range result.RolesCreated {
+		log.Printf("Created role %q", role)
+	}
+	email := bootstrap.AdminEmail
+	switch {
+	case result.GeneratedPassword != "":
+		log.Printf("Created admin %s", email)
+		fmt.Printf("Password of %s: %s (shown only once)\n", email, result.GeneratedPassword)
+	case result.AdminCreated:
+		log.Printf("Created admin %s", email)
+	case result.AdminPromoted:
+		log.Printf("Gave existing user %s the admin role", email)
+	case result.AdminSkipped:
+		slog.Warn("Bootstrap admin not created: set BOOTSTRAP_ADMIN_PASSWORD or run the seed command", "email", email)
+	case result.AdminDeleted:
+		slog.Warn("Bootstrap admin not created: a deleted user has this email", "email", email)
 	}
 }
@@ -0,0 +1,88 @@
+// Command reconcile compares the uploads directory with the database and
+// reports orphaned files, records whose files are missing and blobs with
+// wrong reference counts. With -fix it removes the orphaned files and
+// corrects the reference counts.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	"github.com/ristep/smanzy_backend/internal/config"
+	"github.com/ristep/smanzy_backend/internal/reconcile"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	fix := flag.Bool("fix", false, "Remove orphaned files and correct blob reference counts")
+	minAge := flag.Duration("min-age", reconcile.DefaultMinAge, "Ignore unreferenced files younger than this")
+	dir := flag.String("dir", cfg.Storage.UploadsDir, "Uploads directory")
+	asJSON := flag.Bool("json", false, "Print the full report as JSON")
+	flag.Parse()
+
+	if cfg.Database.DSN == "" {
+		log.Fatal("DB_DSN environment variable is required")
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.Database.DSN), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	reconciler := reconcile.NewReconciler(db, storage.NewLocal(*dir))
+	report, err := reconciler.Run(ctx, reconcile.Options{Fix: *fix, MinAge: *minAge})
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+
+	for _, f := range report.OrphanFiles {
+		status := "orphaned"
+		if f.Removed {
+			status = "removed"
+		}
+		fmt.Printf("%-8s %s (%d bytes)\n", status, f.Key, f.Size)
+	}
+	for _, m := range report.MissingFiles {
+		fmt.Printf("missing  %s %s: %s\n", m.Table, m.ID, m.Key)
+	}
+	for _, b := range report.BlobMismatch {
+		status := "refcount"
+		if b.Fixed {
+			status = "fixed"
+		}
+		fmt.Printf("%-8s blob %s: recorded %d, actual %d\n", status, b.Hash, b.RefCount, b.Actual)
+	}
+	fmt.Println(report.Summary())
+	if !*fix && len(report.OrphanFiles)+len(report.BlobMismatch) > 0 {
+		fmt.Println("Run with -fix to repair")
+	}
+}
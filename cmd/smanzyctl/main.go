@@ -0,0 +1,150 @@
+// Command smanzyctl performs administrative tasks against the database
+// configured with DB_DSN: managing users and roles, inspecting media usage
+// and running maintenance tasks.
+//
+// Usage:
+//
+//	smanzyctl <group> <command> [flags] [arguments]
+//
+// Flags must come before positional arguments. Run smanzyctl without
+// arguments for the list of commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/ristep/smanzy_backend/internal/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `usage: smanzyctl <group> <command> [flags] [arguments]
+
+users:
+  list [-q text] [-role name] [-disabled true|false] [-deleted] [-limit n] [-offset n]
+  create [-name name] [-password password] [-roles user,admin] <email>
+  disable <user>
+  enable <user>
+  grant <user> <role>
+  revoke <user> <role>
+  reset-password [-password password] <user>
+
+media:
+  usage [user]
+  trash-user [-purge] [-dir uploads] <user>
+
+maintenance:
+  purge-trash [-retention 720h] [-dir uploads]
+  reconcile [-fix] [-min-age 1h] [-dir uploads]
+
+jobs:
+  stats
+  retry <id>
+
+<user> is a user ID or email. Passwords that are not given are generated and
+printed once. -dir defaults to the configured uploads directory and
+-retention to the configured trash retention.`
+
+// env is what commands work with
+type env struct {
+	ctx context.Context
+	cfg *config.Config
+	db  *gorm.DB
+	out io.Writer
+}
+
+// commands maps "group command" to its implementation
+var commands = map[string]func(e *env, args []string) error{
+	"users list":              listUsers,
+	"users create":            createUser,
+	"users disable":           func(e *env, args []string) error { return setDisabled(e, args, true) },
+	"users enable":            func(e *env, args []string) error { return setDisabled(e, args, false) },
+	"users grant":             func(e *env, args []string) error { return changeRole(e, args, true) },
+	"users revoke":            func(e *env, args []string) error { return changeRole(e, args, false) },
+	"users reset-password":    resetPassword,
+	"media usage":             mediaUsage,
+	"media trash-user":        trashUserMedia,
+	"maintenance purge-trash": purgeTrash,
+	"maintenance reconcile":   reconcileUploads,
+	"jobs stats":              jobStats,
+	"jobs retry":              retryJob,
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	run, ok := commands[os.Args[1]+" "+os.Args[2]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", strings.Join(os.Args[1:3], " "), usage)
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Database.DSN == "" {
+		log.Fatal("DB_DSN environment variable is required")
+	}
+	db, err := gorm.Open(postgres.Open(cfg.Database.DSN), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(&env{ctx: ctx, cfg: cfg, db: db, out: os.Stdout}, os.Args[3:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// parse parses the flags of a command and checks the number of positional
+// arguments
+func parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int, positional string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		return fmt.Errorf("usage: %s [flags] %s", fs.Name(), positional)
+	}
+	return nil
+}
+
+// table returns a writer aligning tab-separated columns; call Flush when done
+func table(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+}
+
+// formatBytes formats a size in bytes for humans
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/jobs"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/reconcile"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"github.com/ristep/smanzy_backend/internal/storage"
+)
+
+func purgeTrash(e *env, args []string) error {
+	fs := flag.NewFlagSet("maintenance purge-trash", flag.ContinueOnError)
+	retention := fs.Duration("retention", time.Duration(e.cfg.Media.TrashRetentionDays)*24*time.Hour,
+		"Purge media and albums in the trash for longer than this")
+	dir := fs.String("dir", e.cfg.Storage.UploadsDir, "Uploads directory")
+	if err := parse(fs, args, 0, 0, ""); err != nil {
+		return err
+	}
+	if *retention <= 0 {
+		return fmt.Errorf("-retention must be positive")
+	}
+
+	db := e.db.WithContext(e.ctx)
+	cutoff := time.Now().Add(-*retention)
+
+	media, err := services.NewTrashService(db, storage.NewLocal(*dir)).PurgeExpired(e.ctx, cutoff)
+	fmt.Fprintf(e.out, "Purged %d media\n", media)
+	if err != nil {
+		return err
+	}
+	albums, err := services.NewAlbumService(db).PurgeDeletedAlbums(e.ctx, cutoff)
+	fmt.Fprintf(e.out, "Purged %d albums\n", albums)
+	return err
+}
+
+func reconcileUploads(e *env, args []string) error {
+	fs := flag.NewFlagSet("maintenance reconcile", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "Remove orphaned files and correct blob reference counts")
+	minAge := fs.Duration("min-age", reconcile.DefaultMinAge, "Ignore unreferenced files younger than this")
+	dir := fs.String("dir", e.cfg.Storage.UploadsDir, "Uploads directory")
+	if err := parse(fs, args, 0, 0, ""); err != nil {
+		return err
+	}
+
+	report, err := reconcile.NewReconciler(e.db, storage.NewLocal(*dir)).Run(e.ctx, reconcile.Options{Fix: *fix, MinAge: *minAge})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out, report.Summary())
+	return nil
+}
+
+func jobStats(e *env, args []string) error {
+	fs := flag.NewFlagSet("jobs stats", flag.ContinueOnError)
+	if err := parse(fs, args, 0, 0, ""); err != nil {
+		return err
+	}
+
+	stats, err := jobs.NewQueue(e.db.WithContext(e.ctx)).Stats()
+	if err != nil {
+		return err
+	}
+	w := table(e.out)
+	fmt.Fprintln(w, "STATUS\tJOBS")
+	for _, status := range []string{models.JobPending, models.JobRunning, models.JobSucceeded, models.JobDead} {
+		fmt.Fprintf(w, "%s\t%d\n", status, stats[status])
+	}
+	return w.Flush()
+}
+
+func retryJob(e *env, args []string) error {
+	fs := flag.NewFlagSet("jobs retry", flag.ContinueOnError)
+	if err := parse(fs, args, 1, 1, "<id>"); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(fs.Arg(0), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid job ID %q", fs.Arg(0))
+	}
+
+	job, err := jobs.NewQueue(e.db.WithContext(e.ctx)).Retry(uint(id))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Job %d (%s) requeued\n", job.ID, job.Type)
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ristep/smanzy_backend/internal/services"
+	"github.com/ristep/smanzy_backend/internal/storage"
+)
+
+func mediaUsage(e *env, args []string) error {
+	fs := flag.NewFlagSet("media usage", flag.ContinueOnError)
+	if err := parse(fs, args, 0, 1, "[user]"); err != nil {
+		return err
+	}
+
+	var userID uint
+	if fs.NArg() == 1 {
+		user, err := services.NewUserService(e.db.WithContext(e.ctx)).Find(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+		}
+		userID = user.ID
+	}
+
+	usage, err := services.NewUsageService(e.db.WithContext(e.ctx)).MediaUsage(userID)
+	if err != nil {
+		return err
+	}
+
+	w := table(e.out)
+	fmt.Fprintln(w, "ID\tEMAIL\tFILES\tSIZE\tTRASHED\tTRASH SIZE\tVERSIONS\tVERSION SIZE")
+	for _, u := range usage {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%d\t%s\t%d\t%s\n", u.UserID, u.Email,
+			u.Files, formatBytes(u.Bytes), u.TrashedFiles, formatBytes(u.TrashedBytes),
+			u.Versions, formatBytes(u.VersionBytes))
+	}
+	return w.Flush()
+}
+
+func trashUserMedia(e *env, args []string) error {
+	fs := flag.NewFlagSet("media trash-user", flag.ContinueOnError)
+	purge := fs.Bool("purge", false, "Delete the media and their files permanently")
+	dir := fs.String("dir", e.cfg.Storage.UploadsDir, "Uploads directory")
+	if err := parse(fs, args, 1, 1, "<user>"); err != nil {
+		return err
+	}
+
+	db := e.db.WithContext(e.ctx)
+	user, err := services.NewUserService(db).Find(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+
+	trash := services.NewTrashService(db, storage.NewLocal(*dir))
+	moved, err := trash.TrashUserMedia(user.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Moved %d media of user %d (%s) to the trash\n", moved, user.ID, user.Email)
+
+	if *purge {
+		purged, err := trash.PurgeUserMedia(e.ctx, user.ID)
+		fmt.Fprintf(e.out, "Purged %d media\n", purged)
+		return err
+	}
+	return nil
+}
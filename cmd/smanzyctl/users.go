@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/seed"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+func listUsers(e *env, args []string) error {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	q := fs.String("q", "", "Email or name contains")
+	role := fs.String("role", "", "Only users with this role")
+	disabled := fs.String("disabled", "", "Only disabled (true) or enabled (false) users")
+	deleted := fs.Bool("deleted", false, "Include deleted users")
+	limit := fs.Int("limit", 50, "Maximum number of users (at most 100)")
+	offset := fs.Int("offset", 0, "Number of users to skip")
+	if err := parse(fs, args, 0, 0, ""); err != nil {
+		return err
+	}
+
+	opts := services.UserListOptions{
+		Filter:    services.UserFilter{Search: *q, Role: *role, IncludeDeleted: *deleted},
+		SortField: "id",
+		SortAsc:   true,
+		Limit:     *limit,
+		Offset:    *offset,
+	}
+	if *disabled != "" {
+		v, err := strconv.ParseBool(*disabled)
+		if err != nil {
+			return fmt.Errorf("invalid -disabled value %q", *disabled)
+		}
+		opts.Filter.Disabled = &v
+	}
+
+	list, err := services.NewUserListService(e.db.WithContext(e.ctx)).List(opts)
+	if err != nil {
+		return err
+	}
+
+	w := table(e.out)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tROLES\tSTATUS\tCREATED")
+	for _, u := range list.Users {
+		roles := make([]string, len(u.Roles))
+		for i, r := range u.Roles {
+			roles[i] = r.Name
+		}
+		status := "active"
+		switch {
+		case u.DeletedAt != nil:
+			status = "deleted"
+		case u.Disabled:
+			status = "disabled"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Email, u.Name, strings.Join(roles, ","), status,
+			time.UnixMilli(u.CreatedAt).UTC().Format(time.DateOnly))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%d of %d users\n", len(list.Users), list.Total)
+	return nil
+}
+
+func createUser(e *env, args []string) error {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	name := fs.String("name", "", "Display name (defaults to the part of the email before @)")
+	password := fs.String("password", "", "Password (generated when empty)")
+	roles := fs.String("roles", "user", "Comma-separated roles")
+	if err := parse(fs, args, 1, 1, "<email>"); err != nil {
+		return err
+	}
+	email := fs.Arg(0)
+	if !strings.Contains(email, "@") {
+		return fmt.Errorf("invalid email %q", email)
+	}
+	if *name == "" {
+		*name = email[:strings.Index(email, "@")]
+	}
+
+	generated := false
+	if *password == "" {
+		var err error
+		if *password, err = seed.GeneratePassword(); err != nil {
+			return err
+		}
+		generated = true
+	}
+
+	var roleNames []string
+	for _, r := range strings.Split(*roles, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roleNames = append(roleNames, r)
+		}
+	}
+
+	user, err := services.NewUserService(e.db.WithContext(e.ctx)).Create(email, *password, *name, roleNames...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Created user %d (%s)\n", user.ID, user.Email)
+	if generated {
+		fmt.Fprintf(e.out, "Password: %s\n", *password)
+	}
+	return nil
+}
+
+func setDisabled(e *env, args []string, disabled bool) error {
+	action := map[bool]string{true: "disable", false: "enable"}[disabled]
+	fs := flag.NewFlagSet("users "+action, flag.ContinueOnError)
+	if err := parse(fs, args, 1, 1, "<user>"); err != nil {
+		return err
+	}
+
+	users := services.NewUserService(e.db.WithContext(e.ctx))
+	user, err := users.Find(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+	if err := users.SetDisabled(user.ID, disabled); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "User %d (%s) %sd\n", user.ID, user.Email, action)
+	return nil
+}
+
+func changeRole(e *env, args []string, grant bool) error {
+	action := map[bool]string{true: "grant", false: "revoke"}[grant]
+	fs := flag.NewFlagSet("users "+action, flag.ContinueOnError)
+	if err := parse(fs, args, 2, 2, "<user> <role>"); err != nil {
+		return err
+	}
+
+	users := services.NewUserService(e.db.WithContext(e.ctx))
+	user, err := users.Find(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+	role := fs.Arg(1)
+	if grant {
+		err = users.AssignRole(user.ID, role)
+	} else {
+		err = users.RemoveRole(user.ID, role)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%sed role %q for user %d (%s)\n", strings.ToUpper(action[:1])+action[1:], role, user.ID, user.Email)
+	return nil
+}
+
+func resetPassword(e *env, args []string) error {
+	fs := flag.NewFlagSet("users reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "New password (generated when empty)")
+	if err := parse(fs, args, 1, 1, "<user>"); err != nil {
+		return err
+	}
+
+	users := services.NewUserService(e.db.WithContext(e.ctx))
+	user, err := users.Find(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+
+	generated := false
+	if *password == "" {
+		if *password, err = seed.GeneratePassword(); err != nil {
+			return err
+		}
+		generated = true
+	}
+	if err := users.SetPassword(user.ID, *password); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Password of user %d (%s) reset\n", user.ID, user.Email)
+	if generated {
+		fmt.Fprintf(e.out, "Password: %s\n", *password)
+	}
+	return nil
+}
@@ -20,13 +20,18 @@ type CustomClaims struct {
 
 // JWTService handles JWT token generation and validation
 type JWTService struct {
-	secretKey string
+	secretKey  string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
-// NewJWTService creates a new JWT service with the given secret key
-func NewJWTService(secretKey string) *JWTService {
+// NewJWTService creates a new JWT service with the given secret key and
+// lifetimes of access and refresh tokens
+func NewJWTService(secretKey string, accessTTL, refreshTTL time.Duration) *JWTService {
 	return &JWTService{
-		secretKey: secretKey,
+		secretKey:  secretKey,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
 	}
 }
 
@@ -44,14 +49,14 @@ func (js *JWTService) GenerateTokenPair(user *models.User) (*TokenPair, error) {
 		roleNames[i] = role.Name
 	}
 
-	// Generate access token (short-lived: 15 minutes)
-	accessToken, err := js.generateToken(user, roleNames, 15*time.Minute)
+	// Generate access token (short-lived, 15 minutes by default)
+	accessToken, err := js.generateToken(user, roleNames, js.accessTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	// Generate refresh token (long-lived: 7 days)
-	refreshToken, err := js.generateToken(user, roleNames, 7*24*time.Hour)
+	// Generate refresh token (long-lived, 7 days by default)
+	refreshToken, err := js.generateToken(user, roleNames, js.refreshTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
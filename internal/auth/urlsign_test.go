@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner_RoundTrip(t *testing.T) {
+	signer, err := NewURLSigner("topsecret", SigningModeHMAC, true)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	signed := signer.Sign("/api/media/files/a.jpg", now.Add(time.Hour), 42)
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("failed to parse signed url: %v", err)
+	}
+
+	userID, err := signer.Verify(u.Path, u.Query(), now)
+	if err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if userID != 42 {
+		t.Fatalf("expected bound user 42, got %d", userID)
+	}
+
+	// Expired
+	if _, err := signer.Verify(u.Path, u.Query(), now.Add(2*time.Hour)); err != ErrExpiredSignature {
+		t.Fatalf("expected ErrExpiredSignature, got %v", err)
+	}
+
+	// Different file
+	if _, err := signer.Verify("/api/media/files/b.jpg", u.Query(), now); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature for other path, got %v", err)
+	}
+
+	// Tampered user binding
+	q := u.Query()
+	q.Set("uid", "1")
+	if _, err := signer.Verify(u.Path, q, now); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature for tampered uid, got %v", err)
+	}
+
+	// Unsigned
+	if _, err := signer.Verify(u.Path, url.Values{}, now); err != ErrMissingSignature {
+		t.Fatalf("expected ErrMissingSignature, got %v", err)
+	}
+}
+
+func TestURLSigner_NginxSecureLink(t *testing.T) {
+	signer, err := NewURLSigner("topsecret", SigningModeNginx, false)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	signed := signer.Sign("/api/media/files/a.jpg", time.Unix(1700000000, 0), 42)
+
+	// base64url(md5("1700000000/api/media/files/a.jpg42 topsecret")) as computed by nginx
+	if !strings.Contains(signed, "md5=7m5uxp19t5CbjvcyjTOZmg") {
+		t.Fatalf("unexpected secure_link signature in %q", signed)
+	}
+}
+
+func TestNewURLSigner_RejectsUnknownMode(t *testing.T) {
+	if _, err := NewURLSigner("topsecret", "rot13", false); err == nil {
+		t.Fatal("expected error for unknown signing mode")
+	}
+}
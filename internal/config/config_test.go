@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	yml := "server:\n  port: 9000\nauth:\n  jwt_secret: from-file\n  access_token_ttl: 5m\njobs:\n  workers: 4\n"
+	if err := os.WriteFile(path, []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET", "from-env")
+	t.Setenv("MEDIA_STRIP_GPS", "true")
+	t.Setenv("JOB_WORKERS", "") // empty values are ignored
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.Port != 9000 || cfg.Auth.AccessTokenTTL != 5*time.Minute || cfg.Jobs.Workers != 4 {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	if cfg.Auth.JWTSecret != "from-env" || !cfg.Media.StripGPS {
+		t.Errorf("environment values not applied: %+v", cfg)
+	}
+	if cfg.Auth.RefreshTokenTTL != 7*24*time.Hour || cfg.Storage.UploadsDir != "./uploads" {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, err := Load(""); err != nil {
+		t.Errorf("missing default file: unexpected error %v", err)
+	}
+	if _, err := Load("missing.yml"); err == nil {
+		t.Error("missing file: expected an error")
+	}
+	t.Setenv("CONFIG_FILE", "missing.yml")
+	if _, err := Load(""); err == nil {
+		t.Error("missing $CONFIG_FILE: expected an error")
+	}
+	t.Setenv("CONFIG_FILE", "")
+
+	t.Setenv("REFRESH_TOKEN_TTL", "a week")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "REFRESH_TOKEN_TTL") {
+		t.Errorf("invalid duration: error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "postgres://localhost/db"
+	cfg.Auth.JWTSecret = "short-secret"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("development config: unexpected error %v", err)
+	}
+
+	cfg.Env = EnvProduction
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Errorf("short production secret: error = %v", err)
+	}
+	cfg.Auth.JWTSecret = strings.Repeat("x", MinProductionSecretLength)
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("production config: unexpected error %v", err)
+	}
+
+	bad := Default()
+	bad.Media.URLMode = "rot13"
+	bad.Jobs.Workers = -1
+	bad.Tracing.SampleRatio = 1.5
+	err := bad.Validate()
+	for _, want := range []string{"DB_DSN", "JWT_SECRET", "MEDIA_URL_MODE", "JOB_WORKERS", "TRACING_SAMPLE_RATIO"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want a %s error", err, want)
+		}
+	}
+}
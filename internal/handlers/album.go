@@ -27,7 +27,7 @@ func (ah *AlbumHandler) CreateAlbumHandler(c *gin.Context) {
 	// Get current user
 	authUser, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 	user := authUser.(*models.User)
@@ -38,13 +38,13 @@ func (ah *AlbumHandler) CreateAlbumHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
-	album, err := ah.albumService.CreateAlbum(user.ID, req.Title, req.Description)
+	album, err := ah.albumService.WithContext(c.Request.Context()).CreateAlbum(user.ID, req.Title, req.Description)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
@@ -55,13 +55,13 @@ func (ah *AlbumHandler) CreateAlbumHandler(c *gin.Context) {
 func (ah *AlbumHandler) GetAlbumHandler(c *gin.Context) {
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
 		return
 	}
 
-	album, err := ah.albumService.GetAlbumByID(uint(albumID))
+	album, err := ah.albumService.WithContext(c.Request.Context()).GetAlbumByID(uint(albumID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
 		return
 	}
 
@@ -73,14 +73,14 @@ func (ah *AlbumHandler) GetUserAlbumsHandler(c *gin.Context) {
 	// Get current user
 	authUser, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 	user := authUser.(*models.User)
 
-	albums, err := ah.albumService.GetUserAlbums(user.ID)
+	albums, err := ah.albumService.WithContext(c.Request.Context()).GetUserAlbums(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err.Error()))
 		return
 	}
 
@@ -95,7 +95,7 @@ func (ah *AlbumHandler) GetUserAlbumsHandler(c *gin.Context) {
 func (ah *AlbumHandler) UpdateAlbumHandler(c *gin.Context) {
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
 		return
 	}
 
@@ -105,13 +105,13 @@ func (ah *AlbumHandler) UpdateAlbumHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
-	album, err := ah.albumService.UpdateAlbum(uint(albumID), req.Title, req.Description)
+	album, err := ah.albumService.WithContext(c.Request.Context()).UpdateAlbum(uint(albumID), req.Title, req.Description)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
 		return
 	}
 
@@ -122,7 +122,7 @@ func (ah *AlbumHandler) UpdateAlbumHandler(c *gin.Context) {
 func (ah *AlbumHandler) AddMediaToAlbumHandler(c *gin.Context) {
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
 		return
 	}
 
@@ -131,12 +131,12 @@ func (ah *AlbumHandler) AddMediaToAlbumHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
-	if err := ah.albumService.AddMediaToAlbum(uint(albumID), req.MediaID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	if err := ah.albumService.WithContext(c.Request.Context()).AddMediaToAlbum(uint(albumID), req.MediaID); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
@@ -147,7 +147,7 @@ func (ah *AlbumHandler) AddMediaToAlbumHandler(c *gin.Context) {
 func (ah *AlbumHandler) RemoveMediaFromAlbumHandler(c *gin.Context) {
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
 		return
 	}
 
@@ -156,12 +156,12 @@ func (ah *AlbumHandler) RemoveMediaFromAlbumHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
-	if err := ah.albumService.RemoveMediaFromAlbum(uint(albumID), req.MediaID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	if err := ah.albumService.WithContext(c.Request.Context()).RemoveMediaFromAlbum(uint(albumID), req.MediaID); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 		return
 	}
 
@@ -172,14 +172,106 @@ func (ah *AlbumHandler) RemoveMediaFromAlbumHandler(c *gin.Context) {
 func (ah *AlbumHandler) DeleteAlbumHandler(c *gin.Context) {
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
 		return
 	}
 
-	if err := ah.albumService.DeleteAlbum(uint(albumID)); err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+	if err := ah.albumService.WithContext(c.Request.Context()).DeleteAlbum(uint(albumID)); err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Album deleted successfully"})
 }
+
+// canManageAlbum reports whether the current user owns the album or is an
+// admin, writing an error response if not
+func canManageAlbum(c *gin.Context, album *models.Album) bool {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return false
+	}
+	user := authUser.(*models.User)
+
+	if album.UserID != user.ID && !user.HasRole("admin") {
+		c.JSON(http.StatusForbidden, errorResponse(c, "Forbidden"))
+		return false
+	}
+	return true
+}
+
+// GetDeletedAlbumsHandler retrieves the current user's albums in the trash
+func (ah *AlbumHandler) GetDeletedAlbumsHandler(c *gin.Context) {
+	// Get current user
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+	user := authUser.(*models.User)
+
+	albums, err := ah.albumService.WithContext(c.Request.Context()).GetDeletedUserAlbums(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err.Error()))
+		return
+	}
+
+	if albums == nil {
+		albums = []models.Album{}
+	}
+
+	c.JSON(http.StatusOK, albums)
+}
+
+// RestoreAlbumHandler moves an album out of the trash
+func (ah *AlbumHandler) RestoreAlbumHandler(c *gin.Context) {
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
+		return
+	}
+
+	deleted, err := ah.albumService.WithContext(c.Request.Context()).GetDeletedAlbumByID(uint(albumID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
+		return
+	}
+	if !canManageAlbum(c, deleted) {
+		return
+	}
+
+	album, err := ah.albumService.WithContext(c.Request.Context()).RestoreAlbum(uint(albumID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// PermanentlyDeleteAlbumHandler deletes an album for good, whether or not
+// it is in the trash. The media in the album are kept.
+func (ah *AlbumHandler) PermanentlyDeleteAlbumHandler(c *gin.Context) {
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid album ID"))
+		return
+	}
+
+	album, err := ah.albumService.WithContext(c.Request.Context()).GetAlbumByIDUnscoped(uint(albumID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
+		return
+	}
+	if !canManageAlbum(c, album) {
+		return
+	}
+
+	if err := ah.albumService.WithContext(c.Request.Context()).PermanentlyDeleteAlbum(uint(albumID)); err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Album permanently deleted"})
+}
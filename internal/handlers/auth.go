@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,7 +12,10 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/logging"
+	"github.com/ristep/smanzy_backend/internal/metrics"
 	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
 )
 
 // AuthHandler handles authentication-related HTTP requests
@@ -58,40 +63,47 @@ type SuccessResponse struct {
 
 // ErrorResponse represents an error API response
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"` // Quote when reporting the problem
+}
+
+// errorResponse builds an error response carrying the request ID
+func errorResponse(c *gin.Context, message string) ErrorResponse {
+	return ErrorResponse{Error: message, RequestID: logging.RequestID(c.Request.Context())}
 }
 
 // RegisterHandler handles user registration
 func (ah *AuthHandler) RegisterHandler(c *gin.Context) {
+	defer func() { metrics.ObserveAuth("register", c.Writer.Status()) }()
 	var req RegisterRequest
 
 	// Validate JSON input
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
 	// Check if user already exists
 	var existingUser models.User
-	if err := ah.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "User already exists"})
+	if err := ah.db.WithContext(c.Request.Context()).Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "User already exists"))
 		return
 	} else if err != gorm.ErrRecordNotFound {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to process password"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to process password"))
 		return
 	}
 
 	// Get or create the default "user" role
 	var userRole models.Role
-	if err := ah.db.FirstOrCreate(&userRole, models.Role{Name: "user"}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+	if err := ah.db.WithContext(c.Request.Context()).FirstOrCreate(&userRole, models.Role{Name: "user"}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
@@ -109,21 +121,21 @@ func (ah *AuthHandler) RegisterHandler(c *gin.Context) {
 		Roles:    []models.Role{userRole},
 	}
 
-	if err := ah.db.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create user"})
+	if err := ah.db.WithContext(c.Request.Context()).Create(&newUser).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to create user"))
 		return
 	}
 
 	// Load the user with roles
-	if err := ah.db.Preload("Roles").First(&newUser, newUser.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve user"})
+	if err := ah.db.WithContext(c.Request.Context()).Preload("Roles").First(&newUser, newUser.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to retrieve user"))
 		return
 	}
 
 	// Generate tokens
 	tokenPair, err := ah.jwtService.GenerateTokenPair(&newUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate tokens"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to generate tokens"))
 		return
 	}
 
@@ -136,35 +148,41 @@ func (ah *AuthHandler) RegisterHandler(c *gin.Context) {
 
 // LoginHandler handles user login
 func (ah *AuthHandler) LoginHandler(c *gin.Context) {
+	defer func() { metrics.ObserveAuth("login", c.Writer.Status()) }()
 	var req LoginRequest
 
 	// Validate JSON input
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
 	// Find user by email
 	var user models.User
-	if err := ah.db.Preload("Roles").Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := ah.db.WithContext(c.Request.Context()).Preload("Roles").Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Invalid email or password"})
+			c.JSON(http.StatusUnauthorized, errorResponse(c, "Invalid email or password"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
 	// Compare passwords
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Invalid email or password"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Invalid email or password"))
+		return
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, errorResponse(c, "Account is disabled"))
 		return
 	}
 
 	// Generate tokens
 	tokenPair, err := ah.jwtService.GenerateTokenPair(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate tokens"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to generate tokens"))
 		return
 	}
 
@@ -177,36 +195,42 @@ func (ah *AuthHandler) LoginHandler(c *gin.Context) {
 
 // RefreshHandler handles token refresh
 func (ah *AuthHandler) RefreshHandler(c *gin.Context) {
+	defer func() { metrics.ObserveAuth("refresh", c.Writer.Status()) }()
 	var req RefreshRequest
 
 	// Validate JSON input
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
 	// Validate the refresh token
 	claims, err := ah.jwtService.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Invalid refresh token"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Invalid refresh token"))
 		return
 	}
 
 	// Fetch the user from the database
 	var user models.User
-	if err := ah.db.Preload("Roles").First(&user, claims.UserID).Error; err != nil {
+	if err := ah.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, claims.UserID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "User not found"})
+			c.JSON(http.StatusUnauthorized, errorResponse(c, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, errorResponse(c, "Account is disabled"))
 		return
 	}
 
 	// Generate a new token pair
 	tokenPair, err := ah.jwtService.GenerateTokenPair(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate tokens"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to generate tokens"))
 		return
 	}
 
@@ -221,13 +245,13 @@ func (ah *AuthHandler) ProfileHandler(c *gin.Context) {
 	// Get user from context (set by middleware)
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 
 	userObj, ok := user.(*models.User)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Invalid user data"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Invalid user data"))
 		return
 	}
 
@@ -238,14 +262,14 @@ func (ah *AuthHandler) ProfileHandler(c *gin.Context) {
 func (ah *AuthHandler) UpdateProfileHandler(c *gin.Context) {
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
 	// Get user from context
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 
@@ -273,9 +297,12 @@ func (ah *AuthHandler) UpdateProfileHandler(c *gin.Context) {
 	if req.Gender != "" {
 		userObj.Gender = req.Gender
 	}
+	if req.StripGPS != nil {
+		userObj.StripGPS = *req.StripGPS
+	}
 
-	if err := ah.db.Save(userObj).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update profile"})
+	if err := ah.db.WithContext(c.Request.Context()).Save(userObj).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to update profile"))
 		return
 	}
 
@@ -287,7 +314,7 @@ func (ah *AuthHandler) DeleteProfileHandler(c *gin.Context) {
 	// Get user from context
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 
@@ -298,14 +325,14 @@ func (ah *AuthHandler) DeleteProfileHandler(c *gin.Context) {
 	// For a full delete of the user, we also need to be careful with the Soft Delete.
 
 	// 1. Clear roles association
-	if err := ah.db.Model(userObj).Association("Roles").Clear(); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to clear roles"})
+	if err := ah.db.WithContext(c.Request.Context()).Model(userObj).Association("Roles").Clear(); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to clear roles"))
 		return
 	}
 
 	// 2. Delete the user (this will be a soft delete because of DeletedAt field)
-	if err := ah.db.Delete(userObj).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete profile"})
+	if err := ah.db.WithContext(c.Request.Context()).Delete(userObj).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to delete profile"))
 		return
 	}
 
@@ -314,24 +341,101 @@ func (ah *AuthHandler) DeleteProfileHandler(c *gin.Context) {
 
 // UserHandler represents handlers for user management
 type UserHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	listing *services.UserListService
 }
 
 // NewUserHandler creates a new user handler
 func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+	return &UserHandler{db: db, listing: services.NewUserListService(db)}
 }
 
-// GetAllUsersHandler returns all users (admin only)
+// GetAllUsersHandler returns a page of users with their roles (admin only).
+// Query params:
+//
+//	q (email or name contains), role, verified, disabled (true, false), country,
+//	created_after, created_before (milliseconds since epoch or RFC 3339),
+//	include_deleted (true), sort (created_at, email, name, id),
+//	order (asc, desc), limit (default and max 100), offset
 func (uh *UserHandler) GetAllUsersHandler(c *gin.Context) {
-	var users []models.User
+	opts, err := parseUserListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
+		return
+	}
 
-	if err := uh.db.Preload("Roles").Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+	list, err := uh.listing.WithContext(c.Request.Context()).List(opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
-	c.JSON(http.StatusOK, SuccessResponse{Data: users})
+	c.JSON(http.StatusOK, SuccessResponse{Data: list})
+}
+
+// parseUserListOptions reads the filter, sort and pagination parameters of
+// the user listing
+func parseUserListOptions(c *gin.Context) (services.UserListOptions, error) {
+	opts := services.UserListOptions{
+		Filter: services.UserFilter{
+			Search:         c.Query("q"),
+			Role:           c.Query("role"),
+			Country:        c.Query("country"),
+			IncludeDeleted: c.Query("include_deleted") == "true",
+		},
+	}
+
+	if v := c.Query("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, errors.New(`verified must be "true" or "false"`)
+		}
+		opts.Filter.Verified = &verified
+	}
+	if v := c.Query("disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, errors.New(`disabled must be "true" or "false"`)
+		}
+		opts.Filter.Disabled = &disabled
+	}
+
+	var err error
+	for name, dst := range map[string]*int64{
+		"created_after":  &opts.Filter.CreatedAfter,
+		"created_before": &opts.Filter.CreatedBefore,
+	} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = parseTimestamp(v); err != nil {
+				return opts, fmt.Errorf("invalid %s: use milliseconds since epoch or RFC 3339", name)
+			}
+		}
+	}
+
+	opts.SortField = c.DefaultQuery("sort", "created_at")
+	if _, ok := services.UserSortFields[opts.SortField]; !ok {
+		return opts, errors.New("sort must be one of created_at, email, name, id")
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		opts.SortAsc = true
+	case "desc":
+	default:
+		return opts, errors.New(`order must be "asc" or "desc"`)
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			opts.Limit = min(v, services.MaxUserPageSize)
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			opts.Offset = v
+		}
+	}
+
+	return opts, nil
 }
 
 // GetUserByIDHandler returns a specific user by ID (admin only)
@@ -339,12 +443,12 @@ func (uh *UserHandler) GetUserByIDHandler(c *gin.Context) {
 	userID := c.Param("id")
 
 	var user models.User
-	if err := uh.db.Preload("Roles").First(&user, userID).Error; err != nil {
+	if err := uh.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "User not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
@@ -360,6 +464,9 @@ type UpdateUserRequest struct {
 	City    string `json:"city"`
 	Country string `json:"country"`
 	Gender  string `json:"gender"`
+
+	// StripGPS is optional so that omitting it leaves the setting unchanged
+	StripGPS *bool `json:"strip_gps"`
 }
 
 // UpdateUserHandler updates a user (user can update self, admin can update anyone)
@@ -368,14 +475,14 @@ func (uh *UserHandler) UpdateUserHandler(c *gin.Context) {
 	var req UpdateUserRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
 	// Get current user from context
 	currentUser, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 
@@ -392,18 +499,18 @@ func (uh *UserHandler) UpdateUserHandler(c *gin.Context) {
 			}
 		}
 		if !isAdmin {
-			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+			c.JSON(http.StatusForbidden, errorResponse(c, "Forbidden"))
 			return
 		}
 	}
 
 	var user models.User
-	if err := uh.db.First(&user, userID).Error; err != nil {
+	if err := uh.db.WithContext(c.Request.Context()).First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "User not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
@@ -429,14 +536,17 @@ func (uh *UserHandler) UpdateUserHandler(c *gin.Context) {
 	if req.Gender != "" {
 		user.Gender = req.Gender
 	}
+	if req.StripGPS != nil {
+		user.StripGPS = *req.StripGPS
+	}
 
-	if err := uh.db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update user"})
+	if err := uh.db.WithContext(c.Request.Context()).Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to update user"))
 		return
 	}
 
 	// Reload with roles
-	uh.db.Preload("Roles").First(&user, userID)
+	uh.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, userID)
 
 	c.JSON(http.StatusOK, SuccessResponse{Data: user})
 }
@@ -446,17 +556,17 @@ func (uh *UserHandler) DeleteUserHandler(c *gin.Context) {
 	userID := c.Param("id")
 
 	var user models.User
-	if err := uh.db.First(&user, userID).Error; err != nil {
+	if err := uh.db.WithContext(c.Request.Context()).First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "User not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
-	if err := uh.db.Delete(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete user"})
+	if err := uh.db.WithContext(c.Request.Context()).Delete(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to delete user"))
 		return
 	}
 
@@ -474,7 +584,7 @@ func (uh *UserHandler) AssignRoleHandler(c *gin.Context) {
 	var req AssignRoleRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
@@ -482,38 +592,38 @@ func (uh *UserHandler) AssignRoleHandler(c *gin.Context) {
 	roleName := strings.ToLower(strings.TrimSpace(req.RoleName))
 
 	var user models.User
-	if err := uh.db.Preload("Roles").First(&user, userID).Error; err != nil {
+	if err := uh.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "User not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
 	// Find or create the role
 	var role models.Role
-	if err := uh.db.FirstOrCreate(&role, models.Role{Name: roleName}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+	if err := uh.db.WithContext(c.Request.Context()).FirstOrCreate(&role, models.Role{Name: roleName}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
 	// Check if user already has this role
 	for _, r := range user.Roles {
 		if r.ID == role.ID {
-			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "User already has this role"})
+			c.JSON(http.StatusBadRequest, errorResponse(c, "User already has this role"))
 			return
 		}
 	}
 
 	// Assign the role
-	if err := uh.db.Model(&user).Association("Roles").Append(&role); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to assign role"})
+	if err := uh.db.WithContext(c.Request.Context()).Model(&user).Association("Roles").Append(&role); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to assign role"))
 		return
 	}
 
 	// Reload user with roles
-	uh.db.Preload("Roles").First(&user, userID)
+	uh.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, userID)
 
 	c.JSON(http.StatusOK, SuccessResponse{Data: user})
 }
@@ -529,19 +639,19 @@ func (uh *UserHandler) RemoveRoleHandler(c *gin.Context) {
 	var req RemoveRoleRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
 		return
 	}
 
 	roleName := strings.ToLower(strings.TrimSpace(req.RoleName))
 
 	var user models.User
-	if err := uh.db.Preload("Roles").First(&user, userID).Error; err != nil {
+	if err := uh.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "User not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
@@ -554,18 +664,18 @@ func (uh *UserHandler) RemoveRoleHandler(c *gin.Context) {
 	}
 
 	if roleToRemove == nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "User doesn't have this role"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "User doesn't have this role"))
 		return
 	}
 
 	// Remove the role
-	if err := uh.db.Model(&user).Association("Roles").Delete(roleToRemove); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to remove role"})
+	if err := uh.db.WithContext(c.Request.Context()).Model(&user).Association("Roles").Delete(roleToRemove); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to remove role"))
 		return
 	}
 
 	// Reload user with roles
-	uh.db.Preload("Roles").First(&user, userID)
+	uh.db.WithContext(c.Request.Context()).Preload("Roles").First(&user, userID)
 
 	c.JSON(http.StatusOK, SuccessResponse{Data: user})
 }
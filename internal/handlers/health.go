@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/migrate"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Health check statuses
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthCheck is the result of checking one dependency
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs int64   `json:"latency_ms"`
+	FreeBytes *uint64 `json:"free_bytes,omitempty"` // storage
+	Version   *int64  `json:"version,omitempty"`    // migrations: newest applied
+	Latest    *int64  `json:"latest,omitempty"`     // migrations: newest in this build
+}
+
+// HealthReport is the body of the health endpoints
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthHandler answers liveness and readiness probes
+type HealthHandler struct {
+	db           *gorm.DB
+	store        *storage.Local
+	migrator     *migrate.Migrator
+	timeout      time.Duration
+	minFreeBytes uint64
+}
+
+// NewHealthHandler creates a health handler. Readiness checks share timeout;
+// minFreeBytes is the free space required in the uploads directory (0
+// disables the check).
+func NewHealthHandler(db *gorm.DB, store *storage.Local, migrator *migrate.Migrator, timeout time.Duration, minFreeBytes uint64) *HealthHandler {
+	return &HealthHandler{
+		db:           db,
+		store:        store,
+		migrator:     migrator,
+		timeout:      timeout,
+		minFreeBytes: minFreeBytes,
+	}
+}
+
+// LiveHandler reports that the process is running and able to serve
+// requests. It checks no dependencies, so an outage of the database does
+// not get the process restarted.
+func (hh *HealthHandler) LiveHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthReport{Status: HealthOK})
+}
+
+// ReadyHandler reports whether the instance can handle traffic: the
+// database answers, the uploads directory is writable with enough free
+// space and the schema is up to date. Returns 503 with the result of every
+// check when one fails.
+func (hh *HealthHandler) ReadyHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), hh.timeout)
+	defer cancel()
+
+	report := HealthReport{
+		Status: HealthOK,
+		Checks: map[string]HealthCheck{
+			"database":   timed(func(check *HealthCheck) error { return hh.checkDatabase(ctx) }),
+			"storage":    timed(func(check *HealthCheck) error { return hh.checkStorage(ctx, check) }),
+			"migrations": timed(func(check *HealthCheck) error { return hh.checkMigrations(ctx, check) }),
+		},
+	}
+
+	status := http.StatusOK
+	for _, check := range report.Checks {
+		if check.Status != HealthOK {
+			report.Status = HealthUnavailable
+			status = http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(status, report)
+}
+
+// timed runs a check and records its outcome and duration
+func timed(fn func(check *HealthCheck) error) HealthCheck {
+	check := HealthCheck{Status: HealthOK}
+	start := time.Now()
+	if err := fn(&check); err != nil {
+		check.Status = HealthUnavailable
+		check.Error = err.Error()
+	}
+	check.LatencyMs = time.Since(start).Milliseconds()
+	return check
+}
+
+// checkDatabase pings the database. Driver errors can contain connection
+// details, so they are logged rather than returned.
+func (hh *HealthHandler) checkDatabase(ctx context.Context) error {
+	sqlDB, err := hh.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Health check could not reach the database", "error", err)
+		return errors.New("database unreachable")
+	}
+	return nil
+}
+
+// checkStorage verifies that uploads can be written and that enough space
+// is left for them
+func (hh *HealthHandler) checkStorage(ctx context.Context, check *HealthCheck) error {
+	if err := hh.store.CheckWritable(); err != nil {
+		slog.WarnContext(ctx, "Health check could not write to the uploads directory", "dir", hh.store.Root(), "error", err)
+		return errors.New("uploads directory not writable")
+	}
+
+	free, err := hh.store.FreeSpace()
+	if errors.Is(err, storage.ErrFreeSpaceUnsupported) {
+		return nil
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Health check could not read free space", "dir", hh.store.Root(), "error", err)
+		return errors.New("free space unknown")
+	}
+	check.FreeBytes = &free
+	if free < hh.minFreeBytes {
+		return fmt.Errorf("%d bytes free, %d required", free, hh.minFreeBytes)
+	}
+	return nil
+}
+
+// checkMigrations compares the schema version with the migrations in this
+// build. A database behind the build is not ready; one ahead of it (during
+// a rollback) is accepted.
+func (hh *HealthHandler) checkMigrations(ctx context.Context, check *HealthCheck) error {
+	current, latest, err := hh.migrator.Version(ctx)
+	check.Latest = &latest
+	if err != nil {
+		slog.WarnContext(ctx, "Health check could not read the schema version", "error", err)
+		return errors.New("schema version unknown")
+	}
+	check.Version = &current
+	if current < latest {
+		return fmt.Errorf("schema version %d is behind %d, run the migrations", current, latest)
+	}
+	return nil
+}
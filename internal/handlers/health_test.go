@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/migrate"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestReadyHandler_ReportsFailedChecks(t *testing.T) {
+	// Nothing listens on port 1, so every database query fails
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	migrator, err := migrate.New(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	hh := NewHealthHandler(db, storage.NewLocal(t.TempDir()), migrator, 2*time.Second, 0)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health/live", hh.LiveHandler)
+	router.GET("/health/ready", hh.ReadyHandler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("live: expected 200 OK, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ready: expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var report HealthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	want := map[string]string{"database": HealthUnavailable, "migrations": HealthUnavailable, "storage": HealthOK}
+	for name, status := range want {
+		if got := report.Checks[name].Status; got != status {
+			t.Errorf("%s: status %q, want %q (%+v)", name, got, status, report.Checks[name])
+		}
+	}
+}
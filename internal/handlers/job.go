@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/jobs"
+	"gorm.io/gorm"
+)
+
+// JobHandler exposes the background job queue to administrators
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// ListJobsHandler returns a paginated list of jobs, newest first
+// Query params: status, type, limit (default 100), offset (default 0)
+func (jh *JobHandler) ListJobsHandler(c *gin.Context) {
+	limit := 100
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			offset = v
+		}
+	}
+
+	list, total, err := jh.queue.List(c.Query("status"), c.Query("type"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"jobs":  list,
+		"total": total,
+	}})
+}
+
+// JobStatsHandler returns the number of jobs per status
+func (jh *JobHandler) JobStatsHandler(c *gin.Context) {
+	stats, err := jh.queue.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: stats})
+}
+
+// RetryJobHandler puts a dead job back in the queue
+func (jh *JobHandler) RetryJobHandler(c *gin.Context) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid job ID"))
+		return
+	}
+
+	job, err := jh.queue.Retry(uint(jobID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, errorResponse(c, "Job not found"))
+		case errors.Is(err, jobs.ErrNotRetryable):
+			c.JSON(http.StatusConflict, errorResponse(c, err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: job})
+}
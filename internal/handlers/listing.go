@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// publicMediaColumns are the media columns exposed by the public listing
+const publicMediaColumns = "id, filename, title, description, alt_text, credit, license, url, type, mime_type, size, width, height, taken_at, created_at, user_id"
+
+// parseMediaListOptions reads the filter, sort and pagination parameters
+// shared by media listings:
+//
+//	type, mime (prefix), user_id, created_after, created_before (milliseconds
+//	since epoch or RFC 3339), min_size, max_size (bytes), tags, tag_match,
+//	sort (created_at, size, filename), order (asc, desc), limit (max 100),
+//	cursor, offset, with_total (true or false; by default the total is
+//	counted for offset pages and skipped for cursor pages)
+func parseMediaListOptions(c *gin.Context) (services.MediaListOptions, error) {
+	opts := services.MediaListOptions{
+		Filter: services.MediaFilter{
+			Type:       c.Query("type"),
+			MimePrefix: c.Query("mime"),
+		},
+		Cursor: c.Query("cursor"),
+	}
+	// Offset pagination has always returned the total
+	switch c.Query("with_total") {
+	case "true":
+		opts.WithTotal = true
+	case "false":
+	case "":
+		opts.WithTotal = opts.Cursor == ""
+	default:
+		return opts, errors.New(`with_total must be "true" or "false"`)
+	}
+
+	var err error
+	if opts.Filter.Tags, opts.Filter.MatchAllTags, err = parseTagFilter(c); err != nil {
+		return opts, err
+	}
+
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return opts, errors.New("invalid user_id")
+		}
+		opts.Filter.UserID = uint(id)
+	}
+
+	for name, dst := range map[string]*int64{
+		"created_after":  &opts.Filter.CreatedAfter,
+		"created_before": &opts.Filter.CreatedBefore,
+	} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = parseTimestamp(v); err != nil {
+				return opts, fmt.Errorf("invalid %s: use milliseconds since epoch or RFC 3339", name)
+			}
+		}
+	}
+
+	for name, dst := range map[string]*int64{
+		"min_size": &opts.Filter.MinSize,
+		"max_size": &opts.Filter.MaxSize,
+	} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = strconv.ParseInt(v, 10, 64); err != nil || *dst < 0 {
+				return opts, fmt.Errorf("invalid %s", name)
+			}
+		}
+	}
+
+	opts.SortField = c.DefaultQuery("sort", "created_at")
+	if _, ok := services.MediaSortFields[opts.SortField]; !ok {
+		return opts, errors.New("sort must be one of created_at, size, filename")
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		opts.SortAsc = true
+	case "desc":
+	default:
+		return opts, errors.New(`order must be "asc" or "desc"`)
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			opts.Limit = min(v, services.MaxMediaPageSize)
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			opts.Offset = v
+		}
+	}
+
+	return opts, nil
+}
+
+// parseTimestamp parses milliseconds since epoch or an RFC 3339 time
+func parseTimestamp(v string) (int64, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// writeMediaList runs a media listing and writes the page or an error
+func (mh *MediaHandler) writeMediaList(c *gin.Context, opts services.MediaListOptions) {
+	list, err := mh.listing.WithContext(c.Request.Context()).List(opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid cursor"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: list})
+}
+
+// ListUserMediasHandler returns the current user's own uploads with the same
+// filters, sorting and pagination as the public listing
+func (mh *MediaHandler) ListUserMediasHandler(c *gin.Context) {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+	user := authUser.(*models.User)
+
+	opts, err := parseMediaListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
+		return
+	}
+	opts.Filter.UserID = user.ID
+
+	mh.writeMediaList(c, opts)
+}
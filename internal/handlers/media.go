@@ -1,44 +1,105 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/mediaproc"
+	"github.com/ristep/smanzy_backend/internal/metrics"
 	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"github.com/ristep/smanzy_backend/internal/storage"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MediaHandler handles media-related HTTP requests
 type MediaHandler struct {
 	db        *gorm.DB
-	uploadDir string
+	store     *storage.Local
+	blobs     *services.BlobService
+	trash     *services.TrashService
+	tags      *services.TagService
+	listing   *services.MediaListService
+	processor *mediaproc.Processor
+	urlSigner *auth.URLSigner
+	stripGPS  bool // Strip GPS data from all uploaded images
+
+	maxVersions int // Previous files kept per media record
 }
 
-// NewMediaHandler creates a new media handler
-func NewMediaHandler(db *gorm.DB) *MediaHandler {
-	// Ensure upload directory exists
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		_ = os.MkdirAll(uploadDir, 0755)
+// NewMediaHandler creates a new media handler.
+// processor and urlSigner may be nil, in which case audio/video probing and
+// signed URLs are unavailable. When stripGPS is set, location data is
+// removed from every uploaded image; otherwise only for users who opted in.
+// Up to maxVersions replaced files are kept per media record for restoring.
+func NewMediaHandler(db *gorm.DB, store *storage.Local, processor *mediaproc.Processor, urlSigner *auth.URLSigner, stripGPS bool, maxVersions int) *MediaHandler {
+	return &MediaHandler{
+		db:          db,
+		store:       store,
+		blobs:       services.NewBlobService(db, store),
+		trash:       services.NewTrashService(db, store),
+		tags:        services.NewTagService(db),
+		listing:     services.NewMediaListService(db),
+		processor:   processor,
+		urlSigner:   urlSigner,
+		stripGPS:    stripGPS,
+		maxVersions: maxVersions,
 	}
+}
 
-	return &MediaHandler{
-		db:        db,
-		uploadDir: uploadDir,
+// removeFiles deletes files whose last reference was released
+func (mh *MediaHandler) removeFiles(ctx context.Context, storedNames []string) {
+	for _, name := range storedNames {
+		if name == "" {
+			continue
+		}
+		if err := mh.blobs.WithContext(ctx).RemoveFile(name); err != nil {
+			slog.WarnContext(ctx, "Failed to delete file", "file", name, "error", err)
+		}
 	}
 }
 
+// enqueueProcessing schedules unfinished background processing of audio
+// and video inside tx, so the job is only queued if the media record is saved
+func (mh *MediaHandler) enqueueProcessing(tx *gorm.DB, media *models.Media) error {
+	if mh.processor == nil {
+		return nil
+	}
+	switch {
+	case media.ProbeStatus == models.ProbePending:
+		return mh.processor.EnqueueProbe(tx, media.ID)
+	case media.HLSStatus == models.HLSPending || media.HLSStatus == models.HLSProcessing:
+		return mh.processor.EnqueueHLS(tx, media.ID)
+	}
+	return nil
+}
+
+// mediaFileURL returns the public URL for a stored file
+func mediaFileURL(storedName string) string {
+	return "/api/media/files/" + storedName
+}
+
 // UploadHandler handles file uploads
 func (mh *MediaHandler) UploadHandler(c *gin.Context) {
 	// Get current user
 	authUser, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 	user := authUser.(*models.User)
@@ -46,39 +107,66 @@ func (mh *MediaHandler) UploadHandler(c *gin.Context) {
 	// Get file from request
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No file uploaded"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "No file uploaded"))
 		return
 	}
 
-	// Generate unique stored name
-	ext := filepath.Ext(file.Filename)
-	uniqueName := fmt.Sprintf("%d_%d%s", user.ID, time.Now().UnixNano(), ext)
-	dst := filepath.Join(mh.uploadDir, uniqueName)
+	// Store the file under its content hash so identical uploads share one copy
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to read file"))
+		return
+	}
+	defer src.Close()
 
-	// Save file
-	if err := c.SaveUploadedFile(file, dst); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save file"})
+	// Classify the file and extract image metadata before it is stored,
+	// since stripping location data changes the content hash
+	upload, err := prepareUpload(src, file, mh.stripGPS || user.StripGPS)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to read file"))
+		return
+	}
+
+	obj, err := mh.store.WithContext(c.Request.Context()).PutHashed(upload.body, filepath.Ext(file.Filename))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to save file"))
 		return
 	}
 
 	// Create media record
 	media := models.Media{
-		Filename:   file.Filename,
-		StoredName: uniqueName,
-		URL:        "/api/media/files/" + uniqueName, // This will need a static file server handler or direct streaming
-		Type:       "file",                           // Simplified, could verify mime type
-		MimeType:   file.Header.Get("Content-Type"),
-		Size:       file.Size,
-		UserID:     user.ID,
+		Filename:    file.Filename,
+		Size:        obj.Size,
+		ContentHash: obj.Hash,
+		UserID:      user.ID,
 	}
+	applyUpload(&media, upload)
 
-	if err := mh.db.Create(&media).Error; err != nil {
-		// Clean up file if DB save fails
-		_ = os.Remove(dst)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save media record"})
+	err = mh.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		blob, err := mh.acquireUpload(c.Request.Context(), tx, obj, file, mh.stripGPS || user.StripGPS)
+		if err != nil {
+			return err
+		}
+		media.StoredName = blob.StoredName
+		media.URL = mediaFileURL(blob.StoredName)
+		if err := tx.Create(&media).Error; err != nil {
+			return err
+		}
+		return mh.enqueueProcessing(tx, &media)
+	})
+	if err != nil {
+		// Clean up file if DB save fails and nothing else references it
+		_ = mh.blobs.WithContext(c.Request.Context()).Discard(obj)
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to save media record"))
 		return
 	}
 
+	// Same content already stored under another name
+	if media.StoredName != obj.Key {
+		_ = mh.blobs.WithContext(c.Request.Context()).Discard(obj)
+	}
+	metrics.ObserveUpload(media.Type, obj.Size)
+
 	c.JSON(http.StatusCreated, SuccessResponse{Data: media})
 }
 
@@ -87,12 +175,12 @@ func (mh *MediaHandler) GetMediaHandler(c *gin.Context) {
 	mediaID := c.Param("id")
 
 	var media models.Media
-	if err := mh.db.First(&media, mediaID).Error; err != nil {
+	if err := mh.db.WithContext(c.Request.Context()).First(&media, mediaID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
@@ -100,8 +188,14 @@ func (mh *MediaHandler) GetMediaHandler(c *gin.Context) {
 	// If we wanted strictly public, we'd skip AuthMiddleware for this route,
 	// but requirement implies "others" (other users) have read access.
 
-	filePath := filepath.Join(mh.uploadDir, media.StoredName)
-	c.File(filePath)
+	// The content behind /api/media/:id changes when the file is replaced,
+	// so clients must revalidate; the ETag makes that a cheap 304.
+	etag := media.ContentHash
+	if etag == "" {
+		etag = media.StoredName
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	mh.serveStoredFile(c, media.StoredName, etag, time.UnixMilli(media.UpdatedAt))
 }
 
 // GetMediaDetailsHandler returns media metadata
@@ -109,12 +203,12 @@ func (mh *MediaHandler) GetMediaDetailsHandler(c *gin.Context) {
 	mediaID := c.Param("id")
 
 	var media models.Media
-	if err := mh.db.First(&media, mediaID).Error; err != nil {
+	if err := mh.db.WithContext(c.Request.Context()).Preload("Renditions").Preload("Tags").First(&media, mediaID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
@@ -129,61 +223,393 @@ func (mh *MediaHandler) ServeFileHandler(c *gin.Context) {
 
 	// Prevent path traversal: the provided name must be the base name
 	if filepath.Base(name) != name {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid filename"})
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid filename"))
+		return
+	}
+
+	// Verify any signature present, and refuse unsigned requests when
+	// signed URLs are enforced
+	signed, ok := mh.verifySignedLink(c, mh.urlSigner != nil && mh.urlSigner.Required())
+	if !ok {
+		return
+	}
+
+	// Files of trashed media stay stored until purged, but are not served
+	trashed, err := mh.trash.WithContext(c.Request.Context()).FileTrashed(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+	if trashed {
+		c.JSON(http.StatusNotFound, errorResponse(c, "File not found"))
 		return
 	}
 
-	filePath := filepath.Join(mh.uploadDir, name)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "File not found"})
+	info, err := mh.store.WithContext(c.Request.Context()).Stat(name)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid filename"))
+		return
+	} else if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, errorResponse(c, "File not found"))
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Filesystem error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Filesystem error"))
 		return
 	}
 
-	c.File(filePath)
+	// Content-addressed names never change content and can be cached forever.
+	// Older names are unique per upload too, but keep them revalidating.
+	// Signed responses are private and must not outlive the link.
+	etag := name
+	if storage.IsContentAddressed(name) {
+		etag = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if signed {
+		expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", max(expires-time.Now().Unix(), 0)))
+	} else if storage.IsContentAddressed(name) {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "public, no-cache")
+	}
+	mh.serveStoredFile(c, name, etag, info.ModTime())
 }
 
-// ListPublicMediasHandler returns a paginated list of medias for public consumption
-// Query params: limit (default 100), offset (default 0)
-func (mh *MediaHandler) ListPublicMediasHandler(c *gin.Context) {
-	limit := 100
-	offset := 0
+// verifySignedLink checks the signature of a signed link (see
+// GetMediaURLHandler) and reports whether the request carried one. Unsigned
+// requests pass unless required is set. On failure a 403 response is
+// written and ok is false.
+func (mh *MediaHandler) verifySignedLink(c *gin.Context, required bool) (signed, ok bool) {
+	if mh.urlSigner == nil {
+		return false, true
+	}
+	query := c.Request.URL.Query()
+	if !mh.urlSigner.HasSignature(query) && !required {
+		return false, true
+	}
 
-	if l := c.Query("limit"); l != "" {
-		if v, err := strconv.Atoi(l); err == nil && v > 0 {
-			limit = v
+	userID, err := mh.urlSigner.Verify(c.Request.URL.Path, query, time.Now())
+	if err != nil {
+		c.JSON(http.StatusForbidden, errorResponse(c, "Invalid or expired link"))
+		return false, false
+	}
+	// Links bound to a user stop working once the account is deleted or
+	// disabled. They are used without credentials, so anyone holding one
+	// can follow it until then.
+	if userID != 0 {
+		var user models.User
+		err := mh.db.WithContext(c.Request.Context()).Select("id", "disabled").First(&user, userID).Error
+		if err != nil || user.Disabled {
+			c.JSON(http.StatusForbidden, errorResponse(c, "Invalid or expired link"))
+			return false, false
 		}
 	}
-	if o := c.Query("offset"); o != "" {
-		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
-			offset = v
+	return true, true
+}
+
+// serveStoredFile writes a stored file with a strong ETag and Last-Modified,
+// answering conditional requests (If-None-Match, If-Modified-Since) with
+// 304 Not Modified and honouring Range requests
+func (mh *MediaHandler) serveStoredFile(c *gin.Context, storedName, etag string, modTime time.Time) {
+	f, err := mh.store.WithContext(c.Request.Context()).Open(storedName)
+	if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, errorResponse(c, "File not found"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Filesystem error"))
+		return
+	}
+	defer f.Close()
+
+	c.Header("ETag", `"`+etag+`"`)
+	http.ServeContent(c.Writer, c.Request, storedName, modTime, f)
+}
+
+// hlsURL returns the URL of a media's HLS master playlist
+func hlsURL(mediaID uint) string {
+	return fmt.Sprintf("/api/media/%d/hls/master.m3u8", mediaID)
+}
+
+// ServeHLSHandler serves the HLS master playlist, variant playlists and
+// segments of a transcoded video, e.g. /api/media/:id/hls/master.m3u8.
+// Requests need a token, like the original file, or a signed link to the
+// master playlist from GetMediaURLHandler, since native players cannot send
+// headers. Playlists served for a signed link have every URI signed in
+// turn with the same expiry.
+func (mh *MediaHandler) ServeHLSHandler(c *gin.Context) {
+	mediaID := c.Param("id")
+	file := strings.TrimPrefix(c.Param("path"), "/")
+
+	signed, ok := mh.verifySignedLink(c, false)
+	if !ok {
+		return
+	}
+	if _, exists := c.Get("user"); !signed && !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+
+	var media models.Media
+	if err := mh.db.WithContext(c.Request.Context()).First(&media, mediaID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
+			return
 		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	if media.HLSStatus != models.HLSReady {
+		c.JSON(http.StatusNotFound, errorResponse(c, "Stream not available"))
+		return
 	}
 
-	// Count total records for pagination
-	var total int64
-	if err := mh.db.Model(&models.Media{}).Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+	key := models.HLSPrefix(media.ID) + "/" + file
+	info, err := mh.store.WithContext(c.Request.Context()).Stat(key)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid path"))
+		return
+	} else if err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, errorResponse(c, "File not found"))
 		return
 	}
 
-	var medias []models.Media
-	if err := mh.db.Select("id, filename, url, type, mime_type, size, created_at, user_id").Order("created_at desc").Limit(limit).Offset(offset).Find(&medias).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+	// Playlists and segments are rewritten if the file is replaced, so they
+	// are keyed to the content they were produced from. Responses to signed
+	// links must not outlive the link.
+	cacheControl := "private, max-age=86400"
+	if filepath.Ext(file) == ".m3u8" {
+		cacheControl = "private, no-cache"
+	}
+	if signed {
+		expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+		cacheControl = fmt.Sprintf("private, max-age=%d", max(expires-time.Now().Unix(), 0))
+	}
+	c.Header("Cache-Control", cacheControl)
+
+	switch filepath.Ext(file) {
+	case ".m3u8":
+		if signed {
+			mh.serveSignedPlaylist(c, key)
+			return
+		}
+		c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	case ".ts":
+		c.Header("Content-Type", "video/mp2t")
+	}
+	mh.serveStoredFile(c, key, media.ContentHash+"/"+file, info.ModTime())
+}
+
+// serveSignedPlaylist serves an HLS playlist requested with a signed link,
+// with its URIs signed for the same expiry and user so the player can
+// follow them
+func (mh *MediaHandler) serveSignedPlaylist(c *gin.Context, key string) {
+	f, err := mh.store.WithContext(c.Request.Context()).Open(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(c, "File not found"))
+		return
+	}
+	defer f.Close()
+	playlist, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Filesystem error"))
 		return
 	}
 
-	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
-		"files": medias,
-		"total": total,
-	}})
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	uid, _ := strconv.ParseUint(c.Query("uid"), 10, 32)
+	// Only files of this stream are signed
+	root := strings.TrimSuffix(c.Request.URL.Path, c.Param("path")) + "/"
+	dir := path.Dir(c.Request.URL.Path)
+	body := signPlaylist(string(playlist), func(uri string) string {
+		target := path.Join(dir, uri)
+		if !strings.HasPrefix(target, root) {
+			return uri
+		}
+		return mh.urlSigner.Sign(target, time.Unix(expires, 0), uint(uid))
+	})
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(body))
 }
 
-// UpdateMediaRequest represents payload for updating media
+// signPlaylist replaces every relative URI line of an HLS playlist with the
+// result of sign. Our playlists carry no URIs inside tags (URI="..."), and
+// absolute URIs are left alone.
+func signPlaylist(playlist string, sign func(uri string) string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		uri := strings.TrimSpace(line)
+		if uri == "" || strings.HasPrefix(uri, "#") || strings.HasPrefix(uri, "/") || strings.Contains(uri, "://") {
+			continue
+		}
+		lines[i] = sign(uri)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Limits for signed URL lifetimes
+const (
+	defaultSignedURLTTL = time.Hour
+	maxSignedURLTTL     = 7 * 24 * time.Hour
+)
+
+// GetMediaURLHandler returns a signed, expiring URL for the media file that
+// works where an Authorization header cannot be sent (e.g. <img> tags)
+// Query params: ttl (seconds, default 3600, max 7 days), bind (true to revoke
+// the link when the current user is deleted or disabled)
+func (mh *MediaHandler) GetMediaURLHandler(c *gin.Context) {
+	mediaID := c.Param("id")
+
+	if mh.urlSigner == nil {
+		c.JSON(http.StatusNotImplemented, errorResponse(c, "URL signing is not configured"))
+		return
+	}
+
+	// Get current user
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+	user := authUser.(*models.User)
+
+	ttl := defaultSignedURLTTL
+	if t := c.Query("ttl"); t != "" {
+		v, err := strconv.Atoi(t)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid ttl"))
+			return
+		}
+		// Clamped before converting, so huge values cannot overflow
+		ttl = time.Duration(min(v, int(maxSignedURLTTL/time.Second))) * time.Second
+	}
+
+	var media models.Media
+	if err := mh.db.WithContext(c.Request.Context()).First(&media, mediaID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	var userID uint
+	if c.Query("bind") == "true" {
+		userID = user.ID
+	}
+
+	expires := time.Now().Add(ttl)
+	data := map[string]interface{}{
+		"url":        mh.urlSigner.Sign(mediaFileURL(media.StoredName), expires, userID),
+		"expires_at": expires.UnixMilli(),
+	}
+	if media.HLSStatus == models.HLSReady {
+		data["hls_url"] = mh.urlSigner.Sign(hlsURL(media.ID), expires, userID)
+	}
+	c.JSON(http.StatusOK, SuccessResponse{Data: data})
+}
+
+// ListPublicMediasHandler returns a page of medias for public consumption.
+// See parseMediaListOptions for the query parameters; pages are linked by
+// next_cursor; total is counted unless a cursor is given (see with_total).
+func (mh *MediaHandler) ListPublicMediasHandler(c *gin.Context) {
+	opts, err := parseMediaListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
+		return
+	}
+	opts.Columns = publicMediaColumns
+
+	mh.writeMediaList(c, opts)
+}
+
+// UpdateMediaRequest represents payload for updating media.
+// Fields that are omitted are left unchanged; an empty string clears
+// the descriptive fields.
 type UpdateMediaRequest struct {
-	Filename string `json:"filename"`
+	Filename    string  `json:"filename"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	AltText     *string `json:"alt_text"`
+	Credit      *string `json:"credit"`
+	License     *string `json:"license"`
+}
+
+// Length limits of the descriptive media fields, in characters
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 5000
+	maxAltTextLength     = 1000
+	maxCreditLength      = 200
+)
+
+// bindForm reads the update fields present in a multipart form
+func (r *UpdateMediaRequest) bindForm(c *gin.Context) {
+	r.Filename = c.PostForm("filename")
+	for name, field := range map[string]**string{
+		"title":       &r.Title,
+		"description": &r.Description,
+		"alt_text":    &r.AltText,
+		"credit":      &r.Credit,
+		"license":     &r.License,
+	} {
+		if value, ok := c.GetPostForm(name); ok {
+			*field = &value
+		}
+	}
+}
+
+// validate trims the descriptive fields and checks their limits
+func (r *UpdateMediaRequest) validate() error {
+	limits := []struct {
+		name  string
+		value *string
+		max   int
+	}{
+		{"title", r.Title, maxTitleLength},
+		{"description", r.Description, maxDescriptionLength},
+		{"alt_text", r.AltText, maxAltTextLength},
+		{"credit", r.Credit, maxCreditLength},
+	}
+	for _, l := range limits {
+		if l.value == nil {
+			continue
+		}
+		*l.value = strings.TrimSpace(*l.value)
+		if utf8.RuneCountInString(*l.value) > l.max {
+			return fmt.Errorf("%s must be at most %d characters", l.name, l.max)
+		}
+	}
+
+	if r.License != nil {
+		*r.License = strings.TrimSpace(*r.License)
+		if !models.IsValidLicense(*r.License) {
+			return fmt.Errorf("license must be one of: %s", strings.Join(models.Licenses, ", "))
+		}
+	}
+	return nil
+}
+
+// apply copies the fields present in the request to media
+func (r *UpdateMediaRequest) apply(media *models.Media) {
+	if r.Filename != "" {
+		media.Filename = r.Filename
+	}
+	if r.Title != nil {
+		media.Title = *r.Title
+	}
+	if r.Description != nil {
+		media.Description = *r.Description
+	}
+	if r.AltText != nil {
+		media.AltText = *r.AltText
+	}
+	if r.Credit != nil {
+		media.Credit = *r.Credit
+	}
+	if r.License != nil {
+		media.License = *r.License
+	}
 }
 
 // UpdateMediaHandler updates media metadata and optionally replaces the file
@@ -193,127 +619,186 @@ func (mh *MediaHandler) UpdateMediaHandler(c *gin.Context) {
 	// Get current user
 	authUser, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 	user := authUser.(*models.User)
 
 	var media models.Media
-	if err := mh.db.Preload("UploadedBy").First(&media, mediaID).Error; err != nil {
+	if err := mh.db.WithContext(c.Request.Context()).Preload("UploadedBy").First(&media, mediaID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
 	// Access Control: Owner or Admin
 	if media.UserID != user.ID && !user.HasRole("admin") {
-		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		c.JSON(http.StatusForbidden, errorResponse(c, "Forbidden"))
 		return
 	}
 
 	// Check if content type is JSON
 	contentType := c.GetHeader("Content-Type")
-	var newFilename string
+	var req UpdateMediaRequest
+	var upload *preparedUpload
+	var replacement *storage.Object
+	var replacementFile *multipart.FileHeader
 
 	if contentType == "application/json" {
-		var req UpdateMediaRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+			c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
 			return
 		}
-		newFilename = req.Filename
 	} else {
 		// Handle multipart/form-data
-		newFilename = c.PostForm("filename")
+		req.bindForm(c)
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
+			return
+		}
 
 		// Check for file replacement
 		file, err := c.FormFile("file")
 		if err == nil {
-			// 1. Delete old file from disk
-			oldPath := filepath.Join(mh.uploadDir, media.StoredName)
-			if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
-				// Log warning but continue
-				fmt.Printf("Warning: Failed to delete old file %s: %v\n", oldPath, err)
+			src, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to read new file"))
+				return
 			}
+			defer src.Close()
 
-			// 2. Save new file
-			ext := filepath.Ext(file.Filename)
-			uniqueName := fmt.Sprintf("%d_%d%s", user.ID, time.Now().UnixNano(), ext)
-			dst := filepath.Join(mh.uploadDir, uniqueName)
+			// Location data is stripped according to the owner's preference
+			upload, err = prepareUpload(src, file, mh.stripGPS || media.UploadedBy.StripGPS)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to read new file"))
+				return
+			}
 
-			if err := c.SaveUploadedFile(file, dst); err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save new file"})
+			replacement, err = mh.store.WithContext(c.Request.Context()).PutHashed(upload.body, filepath.Ext(file.Filename))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to save new file"))
 				return
 			}
+			replacementFile = file
+		}
+	}
+
+	var orphans []string
 
-			// 3. Update media details
-			media.StoredName = uniqueName
-			media.URL = "/api/media/files/" + uniqueName
-			media.MimeType = file.Header.Get("Content-Type")
-			media.Size = file.Size
+	err := mh.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Apply the changes to the locked row so concurrent updates, such as
+		// processing results, are not overwritten with stale values
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&media, media.ID).Error; err != nil {
+			return err
+		}
+
+		if replacement != nil {
+			// Keep the previous file as a version; it takes over the record's
+			// reference to the stored content
+			pruned, err := mh.archiveFile(tx, media.ID, nil)
+			if err != nil {
+				return err
+			}
+			orphans = append(orphans, pruned...)
+
+			blob, err := mh.acquireUpload(c.Request.Context(), tx, replacement, replacementFile, mh.stripGPS || media.UploadedBy.StripGPS)
+			if err != nil {
+				return err
+			}
 			// Note: We don't automatically update Filename unless provided in form
+			applyUpload(&media, upload)
+			media.StoredName = blob.StoredName
+			media.URL = mediaFileURL(blob.StoredName)
+			media.ContentHash = blob.Hash
+			media.Size = blob.Size
+
+			// Renditions were derived from the old file
+			released, err := mh.blobs.ReleaseRenditions(tx, media.ID)
+			if err != nil {
+				return err
+			}
+			orphans = append(orphans, released...)
 		}
-	}
 
-	// Update fields
-	if newFilename != "" {
-		media.Filename = newFilename
+		// Update fields
+		req.apply(&media)
+		if err := tx.Save(&media).Error; err != nil {
+			return err
+		}
+		if replacement != nil {
+			return mh.enqueueProcessing(tx, &media)
+		}
+		return nil
+	})
+	if err != nil {
+		if replacement != nil {
+			_ = mh.blobs.WithContext(c.Request.Context()).Discard(replacement)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to update media"))
+		return
 	}
 
-	if err := mh.db.Save(&media).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update media"})
-		return
+	// Remove pruned versions and renditions only once nothing references
+	// them anymore
+	if replacement != nil {
+		if replacement.Key != media.StoredName {
+			_ = mh.blobs.WithContext(c.Request.Context()).Discard(replacement)
+		}
+		metrics.ObserveUpload(media.Type, replacement.Size)
+		// HLS output of the old file is no longer served. It is replaced by
+		// the next transcode, which may already be running, or removed by
+		// cmd/reconcile if the new file is not a video.
+		mh.removeFiles(c.Request.Context(), orphans)
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{Data: media})
 }
 
-// DeleteMediaHandler deletes media file and record
+// DeleteMediaHandler moves media to the trash
 func (mh *MediaHandler) DeleteMediaHandler(c *gin.Context) {
 	mediaID := c.Param("id")
 
 	// Get current user
 	authUser, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
 		return
 	}
 	user := authUser.(*models.User)
 
 	var media models.Media
-	if err := mh.db.First(&media, mediaID).Error; err != nil {
+	if err := mh.db.WithContext(c.Request.Context()).First(&media, mediaID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
 		return
 	}
 
 	// Access Control: Owner or Admin
 	if media.UserID != user.ID && !user.HasRole("admin") {
-		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		c.JSON(http.StatusForbidden, errorResponse(c, "Forbidden"))
 		return
 	}
 
-	// Delete file from disk
-	filePath := filepath.Join(mh.uploadDir, media.StoredName)
-	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-		// Log error but continue to delete DB record?
-		// Or fail? Usually better to try to clean up DB even if file is gone,
-		// but if file deletion fails due to permission, we might want to know.
-		// For now, let's proceed to delete auth record so we don't have dangling refs.
-		fmt.Printf("Warning: Failed to delete file %s: %v\n", filePath, err)
-	}
-
-	// Delete from DB
-	if err := mh.db.Delete(&media).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete media record"})
+	// Move to the trash; files are kept until the media is purged, either
+	// explicitly or once the trash retention period has passed
+	if err := mh.db.WithContext(c.Request.Context()).Delete(&media).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to delete media record"))
 		return
 	}
 
-	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Media deleted successfully"}})
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Media moved to trash"}})
 }
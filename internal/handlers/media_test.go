@@ -10,8 +10,24 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
+// dryRunDB returns a database that builds queries without running them, so
+// lookups find no records
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable"),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	return db
+}
+
 func TestServeFileHandler_ServesFile(t *testing.T) {
 	// Create temp dir and file
 	tmpDir, err := os.MkdirTemp("", "uploads_test")
@@ -27,8 +43,7 @@ func TestServeFileHandler_ServesFile(t *testing.T) {
 		t.Fatalf("failed to write test file: %v", err)
 	}
 
-	mh := NewMediaHandler(nil)
-	mh.uploadDir = tmpDir
+	mh := NewMediaHandler(dryRunDB(t), storage.NewLocal(tmpDir), nil, nil, false, 0)
 
 	// Set up router
 	gin.SetMode(gin.TestMode)
@@ -53,7 +68,7 @@ func TestServeFileHandler_ServesFile(t *testing.T) {
 }
 
 func TestServeFileHandler_InvalidFilename(t *testing.T) {
-	mh := NewMediaHandler(nil)
+	mh := NewMediaHandler(dryRunDB(t), storage.NewLocal(t.TempDir()), nil, nil, false, 0)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -68,3 +83,84 @@ func TestServeFileHandler_InvalidFilename(t *testing.T) {
 		t.Fatalf("expected 400 Bad Request for invalid filename, got %d", w.Code)
 	}
 }
+
+func TestServeFileHandler_ConditionalRequest(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "uploads_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	mh := NewMediaHandler(dryRunDB(t), storage.NewLocal(tmpDir), nil, nil, false, 0)
+
+	obj, err := mh.store.PutHashed(strings.NewReader("cached content"), ".png")
+	if err != nil {
+		t.Fatalf("failed to store test file: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/files/:name", mh.ServeFileHandler)
+
+	// First request returns the file with caching headers
+	req := httptest.NewRequest(http.MethodGet, "/api/media/files/"+obj.Key, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag != `"`+obj.Hash+`"` {
+		t.Fatalf("expected ETag of content hash, got %q", etag)
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Fatalf("expected immutable Cache-Control, got %q", cc)
+	}
+
+	// Revalidation with the ETag returns 304 without a body
+	req = httptest.NewRequest(http.MethodGet, "/api/media/files/"+obj.Key, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 Not Modified, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("expected empty body on 304, got %q", w.Body.String())
+	}
+}
+
+func TestUpdateMediaRequest_Validate(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	req := UpdateMediaRequest{Title: str("  Sunset  "), License: str("CC-BY-4.0")}
+	if err := req.validate(); err != nil {
+		t.Fatalf("expected valid request, got %v", err)
+	}
+	if *req.Title != "Sunset" {
+		t.Errorf("expected title to be trimmed, got %q", *req.Title)
+	}
+
+	invalid := []UpdateMediaRequest{
+		{Title: str(strings.Repeat("x", maxTitleLength+1))},
+		{AltText: str(strings.Repeat("x", maxAltTextLength+1))},
+		{License: str("GPL")},
+	}
+	for _, r := range invalid {
+		if err := r.validate(); err == nil {
+			t.Errorf("expected validation error for %+v", r)
+		}
+	}
+}
+
+func TestSignPlaylist(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n480p/index.m3u8\n\n/abs/seg.ts\n"
+	got := signPlaylist(playlist, func(uri string) string { return uri + "?sig=x" })
+
+	want := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n480p/index.m3u8?sig=x\n\n/abs/seg.ts\n"
+	if got != want {
+		t.Errorf("signPlaylist() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/search"
+	"gorm.io/gorm"
+)
+
+// maxSearchLimit caps the page size of search results
+const maxSearchLimit = 100
+
+// SearchHandler handles full-text search requests
+type SearchHandler struct {
+	search *search.Service
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(db *gorm.DB) *SearchHandler {
+	return &SearchHandler{search: search.NewService(db)}
+}
+
+// FullTextSearchHandler searches media and albums
+// Query params: q (required), type (media, album or both comma separated),
+// media_type (image, video, audio, file), limit (default 20, max 100), offset (default 0)
+func (sh *SearchHandler) FullTextSearchHandler(c *gin.Context) {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+	user := authUser.(*models.User)
+
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Query parameter q is required"))
+		return
+	}
+
+	query := search.Query{
+		Text:      text,
+		MediaType: c.Query("media_type"),
+		UserID:    user.ID,
+		AllAlbums: user.HasRole("admin"),
+		Limit:     20,
+	}
+
+	if t := c.Query("type"); t != "" {
+		for _, kind := range strings.Split(t, ",") {
+			kind = strings.TrimSpace(kind)
+			if kind != search.KindMedia && kind != search.KindAlbum {
+				c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid type: "+kind))
+				return
+			}
+			query.Kinds = append(query.Kinds, kind)
+		}
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			query.Limit = min(v, maxSearchLimit)
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			query.Offset = v
+		}
+	}
+
+	results, err := sh.search.Search(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Search failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: results})
+}
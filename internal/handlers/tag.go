@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// TagsRequest represents payload for adding tags to media
+type TagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// parseTagFilter reads the tags (comma separated) and tag_match ("all" or
+// "any", default "all") query parameters used to filter media listings
+func parseTagFilter(c *gin.Context) ([]string, bool, error) {
+	param := c.Query("tags")
+	if param == "" {
+		return nil, false, nil
+	}
+
+	names, err := services.NormalizeTags(strings.Split(param, ","))
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch c.DefaultQuery("tag_match", "all") {
+	case "all":
+		return names, true, nil
+	case "any":
+		return names, false, nil
+	default:
+		return nil, false, errInvalidTagMatch
+	}
+}
+
+// errInvalidTagMatch is returned for an unknown tag_match value
+var errInvalidTagMatch = errors.New(`tag_match must be "all" or "any"`)
+
+// AddTagsHandler attaches tags to a media item (Owner or Admin)
+func (mh *MediaHandler) AddTagsHandler(c *gin.Context) {
+	media := mh.loadOwnedMedia(c)
+	if media == nil {
+		return
+	}
+
+	var req TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid input"))
+		return
+	}
+
+	tags, err := mh.tags.WithContext(c.Request.Context()).AddTags(media.ID, req.Tags)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to add tags"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// RemoveTagHandler detaches a tag from a media item (Owner or Admin)
+func (mh *MediaHandler) RemoveTagHandler(c *gin.Context) {
+	media := mh.loadOwnedMedia(c)
+	if media == nil {
+		return
+	}
+
+	tags, err := mh.tags.WithContext(c.Request.Context()).RemoveTag(media.ID, c.Param("tag"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, errorResponse(c, err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to remove tag"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// TagHandler handles tag listing requests
+type TagHandler struct {
+	tags *services.TagService
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(db *gorm.DB) *TagHandler {
+	return &TagHandler{tags: services.NewTagService(db)}
+}
+
+// ListUserTagsHandler returns the tags on the current user's media with counts
+func (th *TagHandler) ListUserTagsHandler(c *gin.Context) {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+	user := authUser.(*models.User)
+
+	tags, err := th.tags.WithContext(c.Request.Context()).UserTags(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// AutocompleteTagsHandler returns tags in use starting with a prefix
+// Query params: q (prefix), limit (default 10, max 50)
+func (th *TagHandler) AutocompleteTagsHandler(c *gin.Context) {
+	limit := 10
+	if l := c.Query("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = min(v, 50)
+		}
+	}
+
+	tags, err := th.tags.WithContext(c.Request.Context()).Autocomplete(c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// trashedMedia is a media record in the trash
+type trashedMedia struct {
+	models.Media
+	DeletedAt int64 `json:"deleted_at"` // When the media was moved to the trash, milliseconds since epoch
+}
+
+// loadTrashedMedia loads a media record in the trash for the current user,
+// writing an error response and returning nil if it is missing or not accessible
+func (mh *MediaHandler) loadTrashedMedia(c *gin.Context) *models.Media {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return nil
+	}
+	user := authUser.(*models.User)
+
+	var media models.Media
+	if err := mh.db.WithContext(c.Request.Context()).Unscoped().Where("deleted_at IS NOT NULL").First(&media, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found in trash"))
+			return nil
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return nil
+	}
+
+	// Access Control: Owner or Admin
+	if media.UserID != user.ID && !user.HasRole("admin") {
+		c.JSON(http.StatusForbidden, errorResponse(c, "Forbidden"))
+		return nil
+	}
+	return &media
+}
+
+// ListTrashHandler returns the current user's media in the trash, most
+// recently deleted first. Admins can pass all=true to see every user's trash.
+// Query params: limit (default and max 100), offset (default 0), all
+func (mh *MediaHandler) ListTrashHandler(c *gin.Context) {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return
+	}
+	user := authUser.(*models.User)
+
+	limit := services.MaxMediaPageSize
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = min(v, services.MaxMediaPageSize)
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			offset = v
+		}
+	}
+
+	query := mh.db.WithContext(c.Request.Context()).Unscoped().Model(&models.Media{}).Where("deleted_at IS NOT NULL")
+	if c.Query("all") != "true" || !user.HasRole("admin") {
+		query = query.Where("user_id = ?", user.ID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	var medias []models.Media
+	if err := query.Order("deleted_at desc").Limit(limit).Offset(offset).Find(&medias).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	files := make([]trashedMedia, len(medias))
+	for i, m := range medias {
+		files[i] = trashedMedia{Media: m, DeletedAt: m.DeletedAt.Time.UnixMilli()}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"files": files,
+		"total": total,
+	}})
+}
+
+// RestoreMediaHandler moves media out of the trash
+func (mh *MediaHandler) RestoreMediaHandler(c *gin.Context) {
+	media := mh.loadTrashedMedia(c)
+	if media == nil {
+		return
+	}
+
+	err := mh.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(media).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		// Processing stops while media is in the trash
+		return mh.enqueueProcessing(tx, media)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to restore media"))
+		return
+	}
+	media.DeletedAt = gorm.DeletedAt{}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: media})
+}
+
+// PurgeMediaHandler permanently deletes media in the trash and its files
+func (mh *MediaHandler) PurgeMediaHandler(c *gin.Context) {
+	media := mh.loadTrashedMedia(c)
+	if media == nil {
+		return
+	}
+
+	if err := mh.trash.WithContext(c.Request.Context()).Purge(media.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found in trash"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to purge media"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Media deleted permanently"}})
+}
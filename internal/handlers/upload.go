@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/imagemeta"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/gorm"
+)
+
+// maxImageMetadataSize caps the size of images read into memory for
+// metadata extraction; larger images are stored without metadata
+const maxImageMetadataSize = 64 << 20
+
+// preparedUpload is an uploaded file ready to be written to storage
+type preparedUpload struct {
+	body     io.Reader
+	mimeType string
+	kind     string          // General category: "image", "video", "audio" or "file"
+	meta     *imagemeta.Info // Image metadata, nil for other kinds
+}
+
+// prepareUpload sniffs the content of an uploaded file to classify it and,
+// for images, extracts EXIF/XMP metadata and strips GPS data if stripGPS is set
+func prepareUpload(src io.Reader, file *multipart.FileHeader, stripGPS bool) (*preparedUpload, error) {
+	br := bufio.NewReaderSize(src, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	// Classify by content so a wrong client Content-Type cannot mislabel files
+	sniffed := http.DetectContentType(head)
+	declared := file.Header.Get("Content-Type")
+	kind := mediaKind(sniffed)
+	if kind == "file" {
+		kind = mediaKind(declared)
+	}
+
+	mimeType := declared
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = sniffed
+	}
+
+	upload := &preparedUpload{body: br, mimeType: mimeType, kind: kind}
+	if kind != "image" || file.Size > maxImageMetadataSize {
+		return upload, nil
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	upload.meta = imagemeta.Extract(data)
+	if stripGPS && upload.meta.HasGPS {
+		imagemeta.StripGPS(data)
+		upload.meta.HasGPS = false
+	}
+	upload.body = bytes.NewReader(data)
+
+	return upload, nil
+}
+
+// acquireUpload acquires the blob of an uploaded file stored as obj inside
+// tx. If the file was removed as unreferenced before it was acquired, it is
+// prepared and stored again under the same key and acquired once more; the
+// lock Acquire holds keeps it from being removed a second time.
+func (mh *MediaHandler) acquireUpload(ctx context.Context, tx *gorm.DB, obj *storage.Object, file *multipart.FileHeader, stripGPS bool) (*models.Blob, error) {
+	blob, err := mh.blobs.Acquire(tx, obj)
+	if !errors.Is(err, services.ErrBlobFileRemoved) {
+		return blob, err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	upload, err := prepareUpload(src, file, stripGPS)
+	if err != nil {
+		return nil, err
+	}
+	again, err := mh.store.WithContext(ctx).PutHashed(upload.body, filepath.Ext(file.Filename))
+	if err != nil {
+		return nil, err
+	}
+	if again.Key != obj.Key {
+		_ = mh.blobs.WithContext(ctx).Discard(again)
+		return nil, fmt.Errorf("upload stored again as %s instead of %s", again.Key, obj.Key)
+	}
+	return mh.blobs.Acquire(tx, obj)
+}
+
+// mediaKind maps a MIME type to the general media category
+func mediaKind(mimeType string) string {
+	for _, kind := range []string{"image", "video", "audio"} {
+		if strings.HasPrefix(mimeType, kind+"/") {
+			return kind
+		}
+	}
+	return "file"
+}
+
+// applyUpload copies the type and metadata of a prepared upload to media,
+// clearing metadata left over from a previous file
+func applyUpload(media *models.Media, upload *preparedUpload) {
+	media.Type = upload.kind
+	media.MimeType = upload.mimeType
+
+	media.Width, media.Height, media.Orientation = 0, 0, 0
+	media.TakenAt = nil
+	media.CameraMake, media.CameraModel = "", ""
+	media.Duration, media.Bitrate = 0, 0
+	media.VideoCodec, media.AudioCodec = "", ""
+
+	// Audio and video are probed in the background after the record is saved;
+	// videos are transcoded to HLS once probed
+	media.ProbeStatus, media.HLSStatus = "", ""
+	if upload.kind == "video" || upload.kind == "audio" {
+		media.ProbeStatus = models.ProbePending
+	}
+
+	meta := upload.meta
+	if meta == nil {
+		return
+	}
+	media.Width = meta.Width
+	media.Height = meta.Height
+	media.Orientation = meta.Orientation
+	media.CameraMake = meta.CameraMake
+	media.CameraModel = meta.CameraModel
+	if meta.TakenAt != nil {
+		takenAt := meta.TakenAt.UnixMilli()
+		media.TakenAt = &takenAt
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// archiveFile moves the current file of a media record into a new version
+// inside tx and prunes versions beyond maxVersions. The version takes over
+// the record's reference to the stored content, so the caller must give
+// the record a new file. When restoring, restored is the version whose file
+// the record takes back; it is deleted after the new version number is
+// taken, so numbers are never reused. It returns the stored names of pruned
+// files; the caller must remove them after commit.
+func (mh *MediaHandler) archiveFile(tx *gorm.DB, mediaID uint, restored *models.MediaVersion) ([]string, error) {
+	// Lock the record so concurrent replacements archive distinct files
+	var current models.Media
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, mediaID).Error; err != nil {
+		return nil, err
+	}
+
+	var latest int
+	if err := tx.Model(&models.MediaVersion{}).Where("media_id = ?", mediaID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	if restored != nil {
+		if err := tx.Delete(restored).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Create(models.NewMediaVersion(&current, latest+1)).Error; err != nil {
+		return nil, err
+	}
+	return mh.blobs.ReleaseVersions(tx, mediaID, mh.maxVersions)
+}
+
+// loadOwnedMedia loads a media record for the current user, writing an
+// error response and returning nil if it is missing or not accessible
+func (mh *MediaHandler) loadOwnedMedia(c *gin.Context) *models.Media {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, "Unauthorized"))
+		return nil
+	}
+	user := authUser.(*models.User)
+
+	var media models.Media
+	if err := mh.db.WithContext(c.Request.Context()).First(&media, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Media not found"))
+			return nil
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return nil
+	}
+
+	// Access Control: Owner or Admin
+	if media.UserID != user.ID && !user.HasRole("admin") {
+		c.JSON(http.StatusForbidden, errorResponse(c, "Forbidden"))
+		return nil
+	}
+	return &media
+}
+
+// ListVersionsHandler returns the previous files of a media record, newest first
+func (mh *MediaHandler) ListVersionsHandler(c *gin.Context) {
+	media := mh.loadOwnedMedia(c)
+	if media == nil {
+		return
+	}
+
+	var versions []models.MediaVersion
+	if err := mh.db.WithContext(c.Request.Context()).Where("media_id = ?", media.ID).Order("version desc").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Database error"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: versions})
+}
+
+// errVersionNotFound is returned when restoring a version that does not exist
+var errVersionNotFound = errors.New("version not found")
+
+// RestoreVersionHandler makes a previous file the current file of a media
+// record again. The file it replaces is kept as a new version.
+func (mh *MediaHandler) RestoreVersionHandler(c *gin.Context) {
+	media := mh.loadOwnedMedia(c)
+	if media == nil {
+		return
+	}
+
+	versionNumber, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "Invalid version"))
+		return
+	}
+
+	var orphans []string
+	err = mh.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var version models.MediaVersion
+		if err := tx.Where("media_id = ? AND version = ?", media.ID, versionNumber).First(&version).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errVersionNotFound
+			}
+			return err
+		}
+
+		// The record takes over the version's reference and the current
+		// file becomes the newest version
+		pruned, err := mh.archiveFile(tx, media.ID, &version)
+		if err != nil {
+			return err
+		}
+		orphans = append(orphans, pruned...)
+
+		if err := tx.First(media, media.ID).Error; err != nil {
+			return err
+		}
+		version.Apply(media)
+
+		// Renditions were derived from the replaced file
+		released, err := mh.blobs.ReleaseRenditions(tx, media.ID)
+		if err != nil {
+			return err
+		}
+		orphans = append(orphans, released...)
+
+		if err := tx.Save(media).Error; err != nil {
+			return err
+		}
+		return mh.enqueueProcessing(tx, media)
+	})
+	if err != nil {
+		if errors.Is(err, errVersionNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(c, "Version not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, "Failed to restore version"))
+		return
+	}
+
+	// HLS output is left to the next transcode, as in UpdateMediaHandler
+	mh.removeFiles(c.Request.Context(), orphans)
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: media})
+}
@@ -0,0 +1,148 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var (
+	jpegExifPrefix = []byte("Exif\x00\x00")
+	jpegXMPPrefix  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	pngSignature   = []byte("\x89PNG\r\n\x1a\n")
+	pngXMPKeyword  = []byte("XML:com.adobe.xmp\x00")
+)
+
+// exifBlocks returns the TIFF-structured EXIF blocks of an image as
+// sub-slices of data, so changes made to them modify data in place
+func exifBlocks(data []byte) [][]byte {
+	var blocks [][]byte
+	jpegSegments(data, func(marker byte, payload []byte) {
+		if marker == 0xE1 && bytes.HasPrefix(payload, jpegExifPrefix) {
+			blocks = append(blocks, payload[len(jpegExifPrefix):])
+		}
+	})
+	pngChunks(data, func(typ string, payload []byte) {
+		if typ == "eXIf" {
+			blocks = append(blocks, payload)
+		}
+	})
+	return blocks
+}
+
+// xmpBlocks returns the uncompressed XMP packets of an image as sub-slices
+// of data
+func xmpBlocks(data []byte) [][]byte {
+	var blocks [][]byte
+	jpegSegments(data, func(marker byte, payload []byte) {
+		if marker == 0xE1 && bytes.HasPrefix(payload, jpegXMPPrefix) {
+			blocks = append(blocks, payload[len(jpegXMPPrefix):])
+		}
+	})
+	pngChunks(data, func(typ string, payload []byte) {
+		if typ == "iTXt" && bytes.HasPrefix(payload, pngXMPKeyword) {
+			if text := pngITXtText(payload[len(pngXMPKeyword):]); text != nil {
+				blocks = append(blocks, text)
+			}
+		}
+	})
+	return blocks
+}
+
+// jpegSegments calls fn for every marker segment before the image data
+func jpegSegments(data []byte, fn func(marker byte, payload []byte)) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // fill byte
+			i++
+			continue
+		case marker == 0xDA || marker == 0xD9: // start of scan, end of image
+			return
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // no payload
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return
+		}
+		fn(marker, data[i+4:i+2+length])
+		i += 2 + length
+	}
+}
+
+// pngChunks calls fn for every chunk of a PNG file
+func pngChunks(data []byte, fn func(typ string, payload []byte)) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return
+	}
+
+	i := len(pngSignature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		if i+12+length > len(data) {
+			return
+		}
+		typ := string(data[i+4 : i+8])
+		fn(typ, data[i+8:i+8+length])
+		if typ == "IEND" {
+			return
+		}
+		i += 12 + length
+	}
+}
+
+// pngITXtText returns the text of an uncompressed iTXt chunk, given the
+// payload following the keyword
+func pngITXtText(rest []byte) []byte {
+	// compression flag, compression method
+	if len(rest) < 2 || rest[0] != 0 {
+		return nil
+	}
+	rest = rest[2:]
+
+	// language tag and translated keyword, both NUL terminated
+	for n := 0; n < 2; n++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil
+		}
+		rest = rest[end+1:]
+	}
+	return rest
+}
+
+// fixPNGChecksums recomputes the CRCs of metadata chunks after they were
+// modified in place
+func fixPNGChecksums(data []byte) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return
+	}
+
+	i := len(pngSignature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		if i+12+length > len(data) {
+			return
+		}
+		typ := string(data[i+4 : i+8])
+		if typ == "eXIf" || typ == "iTXt" {
+			crc := crc32.ChecksumIEEE(data[i+4 : i+8+length])
+			binary.BigEndian.PutUint32(data[i+8+length:], crc)
+		}
+		if typ == "IEND" {
+			return
+		}
+		i += 12 + length
+	}
+}
@@ -0,0 +1,230 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// EXIF tags read by this package
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+)
+
+// exifDateLayout is the timestamp format used by EXIF
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// typeSizes maps EXIF field types to their size in bytes
+var typeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// tiffReader reads a TIFF structure with bounds checking
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is a single 12 byte IFD entry
+type ifdEntry struct {
+	pos   int // Position of the entry within the TIFF block
+	tag   uint16
+	typ   uint16
+	count uint32
+}
+
+func newTIFFReader(data []byte) (*tiffReader, int, bool) {
+	if len(data) < 8 {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return nil, 0, false
+	}
+
+	return &tiffReader{data: data, order: order}, int(order.Uint32(data[4:])), true
+}
+
+// entries returns the entries of the IFD at offset
+func (r *tiffReader) entries(offset int) []ifdEntry {
+	if offset <= 0 || offset+2 > len(r.data) {
+		return nil
+	}
+	n := int(r.order.Uint16(r.data[offset:]))
+	if offset+2+n*12 > len(r.data) {
+		return nil
+	}
+
+	entries := make([]ifdEntry, n)
+	for i := range entries {
+		pos := offset + 2 + i*12
+		entries[i] = ifdEntry{
+			pos:   pos,
+			tag:   r.order.Uint16(r.data[pos:]),
+			typ:   r.order.Uint16(r.data[pos+2:]),
+			count: r.order.Uint32(r.data[pos+4:]),
+		}
+	}
+	return entries
+}
+
+// value returns the raw bytes of an entry's value, which are stored inline
+// when they fit in four bytes and at an offset otherwise
+func (r *tiffReader) value(e ifdEntry) []byte {
+	size, ok := typeSizes[e.typ]
+	if !ok || e.count > uint32(len(r.data)) {
+		return nil
+	}
+	total := size * int(e.count)
+	if total <= 4 {
+		return r.data[e.pos+8 : e.pos+8+total]
+	}
+
+	offset := int(r.order.Uint32(r.data[e.pos+8:]))
+	if offset < 0 || offset+total > len(r.data) {
+		return nil
+	}
+	return r.data[offset : offset+total]
+}
+
+// uint returns the first value of a SHORT or LONG entry
+func (r *tiffReader) uint(e ifdEntry) (int, bool) {
+	v := r.value(e)
+	switch {
+	case e.typ == 3 && len(v) >= 2:
+		return int(r.order.Uint16(v)), true
+	case e.typ == 4 && len(v) >= 4:
+		return int(r.order.Uint32(v)), true
+	}
+	return 0, false
+}
+
+// string returns the value of an ASCII entry
+func (r *tiffReader) string(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	v := r.value(e)
+	if i := bytes.IndexByte(v, 0); i >= 0 {
+		v = v[:i]
+	}
+	return string(bytes.TrimSpace(v))
+}
+
+// parseEXIF fills info from a TIFF-structured EXIF block
+func parseEXIF(data []byte, info *Info) {
+	r, ifd0, ok := newTIFFReader(data)
+	if !ok {
+		return
+	}
+
+	var dateTime, dateTimeOriginal, offsetTime string
+	exifIFD := 0
+
+	for _, e := range r.entries(ifd0) {
+		switch e.tag {
+		case tagMake:
+			info.CameraMake = r.string(e)
+		case tagModel:
+			info.CameraModel = r.string(e)
+		case tagOrientation:
+			if v, ok := r.uint(e); ok && v >= 1 && v <= 8 {
+				info.Orientation = v
+			}
+		case tagDateTime:
+			dateTime = r.string(e)
+		case tagExifIFD:
+			exifIFD, _ = r.uint(e)
+		case tagGPSIFD:
+			if v, ok := r.uint(e); ok && len(r.entries(v)) > 0 {
+				info.HasGPS = true
+			}
+		}
+	}
+
+	for _, e := range r.entries(exifIFD) {
+		switch e.tag {
+		case tagDateTimeOriginal:
+			dateTimeOriginal = r.string(e)
+		case tagOffsetTimeOriginal:
+			offsetTime = r.string(e)
+		}
+	}
+
+	if dateTimeOriginal == "" {
+		dateTimeOriginal = dateTime
+	}
+	if t, ok := parseEXIFTime(dateTimeOriginal, offsetTime); ok {
+		info.TakenAt = &t
+	}
+}
+
+// parseEXIFTime parses an EXIF timestamp. EXIF times carry no zone unless
+// an OffsetTime tag is present, in which case UTC is assumed.
+func parseEXIFTime(value, offset string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if t, err := time.Parse(exifDateLayout+"-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.Parse(exifDateLayout, value)
+	if err != nil || t.Year() < 1800 {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// stripEXIFGPS empties the GPS IFD of a TIFF-structured EXIF block in place.
+// The GPS pointer is kept so no offsets need to be rewritten; it points at
+// an IFD with no entries.
+func stripEXIFGPS(data []byte) bool {
+	r, ifd0, ok := newTIFFReader(data)
+	if !ok {
+		return false
+	}
+
+	gpsIFD := 0
+	for _, e := range r.entries(ifd0) {
+		if e.tag == tagGPSIFD {
+			gpsIFD, _ = r.uint(e)
+		}
+	}
+
+	entries := r.entries(gpsIFD)
+	if len(entries) == 0 {
+		return false
+	}
+
+	// Zero out values stored outside the entries, then the entries and the
+	// next-IFD pointer that follows them
+	for _, e := range entries {
+		if v := r.value(e); len(v) > 4 {
+			clear(v)
+		}
+	}
+	end := gpsIFD + 2 + len(entries)*12 + 4
+	if end > len(data) {
+		end = len(data)
+	}
+	clear(data[gpsIFD:end])
+	return true
+}
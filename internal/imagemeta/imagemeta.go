@@ -0,0 +1,75 @@
+// Package imagemeta extracts metadata (dimensions, EXIF, XMP) from uploaded
+// images and removes GPS location data from them.
+//
+// JPEG (APP1 Exif/XMP segments) and PNG (eXIf chunk) are supported. Stripping
+// is done in place without changing the file layout, so the image data itself
+// is never re-encoded.
+package imagemeta
+
+import (
+	"bytes"
+	"image"
+	"time"
+
+	// Register decoders used by image.DecodeConfig
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Info holds metadata extracted from an image
+type Info struct {
+	Width       int        // Pixel width as displayed (after applying Orientation)
+	Height      int        // Pixel height as displayed
+	Orientation int        // EXIF orientation (1-8), 0 if unknown
+	TakenAt     *time.Time // Capture time, nil if unknown
+	CameraMake  string
+	CameraModel string
+	HasGPS      bool // Whether the file carries GPS location data
+}
+
+// Extract reads metadata from an image. Missing or malformed metadata is
+// not an error; only the fields that could be read are filled in.
+func Extract(data []byte) *Info {
+	info := &Info{}
+
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		info.Width = cfg.Width
+		info.Height = cfg.Height
+	}
+
+	for _, tiff := range exifBlocks(data) {
+		parseEXIF(tiff, info)
+	}
+	for _, xmp := range xmpBlocks(data) {
+		parseXMP(xmp, info)
+	}
+
+	// Dimensions in EXIF refer to the stored pixels; orientations 5-8 are
+	// rotated by 90 degrees when displayed
+	if info.Orientation >= 5 && info.Orientation <= 8 {
+		info.Width, info.Height = info.Height, info.Width
+	}
+
+	return info
+}
+
+// StripGPS removes GPS location data from EXIF and XMP metadata in place
+// and reports whether anything was removed
+func StripGPS(data []byte) bool {
+	stripped := false
+	for _, tiff := range exifBlocks(data) {
+		if stripEXIFGPS(tiff) {
+			stripped = true
+		}
+	}
+	for _, xmp := range xmpBlocks(data) {
+		if stripXMPGPS(xmp) {
+			stripped = true
+		}
+	}
+	if stripped {
+		fixPNGChecksums(data)
+	}
+	return stripped
+}
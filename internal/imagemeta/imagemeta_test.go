@@ -0,0 +1,146 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+// buildEXIF builds a little-endian TIFF block with camera make, orientation
+// 6, a capture time and GPS latitude
+func buildEXIF() []byte {
+	le := binary.LittleEndian
+	buf := make([]byte, 160)
+	copy(buf, "II")
+	le.PutUint16(buf[2:], 42)
+	le.PutUint32(buf[4:], 8)
+
+	entry := func(pos int, tag, typ uint16, count, value uint32) {
+		le.PutUint16(buf[pos:], tag)
+		le.PutUint16(buf[pos+2:], typ)
+		le.PutUint32(buf[pos+4:], count)
+		le.PutUint32(buf[pos+8:], value)
+	}
+
+	// IFD0 at 8: Make, Orientation, Exif pointer, GPS pointer
+	le.PutUint16(buf[8:], 4)
+	entry(10, tagMake, 2, 6, 62)
+	entry(22, tagOrientation, 3, 1, 6)
+	entry(34, tagExifIFD, 4, 1, 68)
+	entry(46, tagGPSIFD, 4, 1, 106)
+	copy(buf[62:], "Canon\x00")
+
+	// Exif IFD at 68: DateTimeOriginal
+	le.PutUint16(buf[68:], 1)
+	entry(70, tagDateTimeOriginal, 2, 20, 86)
+	copy(buf[86:], "2024:05:17 14:30:00\x00")
+
+	// GPS IFD at 106: GPSLatitudeRef (inline), GPSLatitude (3 rationals)
+	le.PutUint16(buf[106:], 2)
+	entry(108, 0x0001, 2, 2, uint32('N'))
+	entry(120, 0x0002, 5, 3, 136)
+	for i := 0; i < 3; i++ {
+		le.PutUint32(buf[136+i*8:], uint32(41+i))
+		le.PutUint32(buf[140+i*8:], 1)
+	}
+
+	return buf
+}
+
+// buildJPEG encodes a 4x2 image with the given EXIF block in an APP1 segment
+func buildJPEG(t *testing.T, exif []byte) []byte {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), exif...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := img.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestExtract_JPEG(t *testing.T) {
+	info := Extract(buildJPEG(t, buildEXIF()))
+
+	if info.CameraMake != "Canon" {
+		t.Errorf("expected camera make Canon, got %q", info.CameraMake)
+	}
+	if info.Orientation != 6 {
+		t.Errorf("expected orientation 6, got %d", info.Orientation)
+	}
+	// Orientation 6 is rotated 90 degrees, so the 4x2 image displays as 2x4
+	if info.Width != 2 || info.Height != 4 {
+		t.Errorf("expected displayed size 2x4, got %dx%d", info.Width, info.Height)
+	}
+	want := time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC)
+	if info.TakenAt == nil || !info.TakenAt.Equal(want) {
+		t.Errorf("expected taken at %v, got %v", want, info.TakenAt)
+	}
+	if !info.HasGPS {
+		t.Error("expected GPS data to be detected")
+	}
+}
+
+func TestStripGPS_JPEG(t *testing.T) {
+	data := buildJPEG(t, buildEXIF())
+	size := len(data)
+
+	if !StripGPS(data) {
+		t.Fatal("expected GPS data to be stripped")
+	}
+	if len(data) != size {
+		t.Fatalf("stripping changed the file size from %d to %d", size, len(data))
+	}
+
+	info := Extract(data)
+	if info.HasGPS {
+		t.Error("GPS data still present after stripping")
+	}
+	if info.CameraMake != "Canon" || info.Orientation != 6 {
+		t.Error("stripping removed non-GPS metadata")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("image no longer decodes after stripping: %v", err)
+	}
+
+	if StripGPS(data) {
+		t.Error("expected nothing to strip the second time")
+	}
+}
+
+func TestStripGPS_XMP(t *testing.T) {
+	xmp := []byte(`<rdf:Description exif:GPSLatitude="41,24.5N" tiff:Model="X100"><exif:GPSLongitude>21,26.1E</exif:GPSLongitude></rdf:Description>`)
+	size := len(xmp)
+
+	info := &Info{}
+	parseXMP(xmp, info)
+	if !info.HasGPS || info.CameraModel != "X100" {
+		t.Fatalf("unexpected XMP info: %+v", info)
+	}
+
+	if !stripXMPGPS(xmp) {
+		t.Fatal("expected GPS properties to be stripped")
+	}
+	if len(xmp) != size || bytes.Contains(xmp, []byte("GPS")) {
+		t.Fatalf("unexpected XMP after stripping: %s", xmp)
+	}
+	if !bytes.Contains(xmp, []byte(`tiff:Model="X100"`)) {
+		t.Fatal("stripping removed non-GPS properties")
+	}
+}
+
+func TestExtract_IgnoresGarbage(t *testing.T) {
+	info := Extract([]byte("definitely not an image"))
+	if info.Width != 0 || info.TakenAt != nil || info.HasGPS {
+		t.Fatalf("expected empty info, got %+v", info)
+	}
+}
@@ -0,0 +1,85 @@
+package imagemeta
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Properties may be written as attributes or as elements
+	xmpGPSAttr    = regexp.MustCompile(`\sexif:GPS\w+\s*=\s*("[^"]*"|'[^']*')`)
+	xmpGPSElement = regexp.MustCompile(`(?s)<exif:(GPS\w+)\b[^>]*?(/>|>.*?</exif:GPS\w+>)`)
+)
+
+// xmpProperty returns the value of a simple XMP property such as
+// "tiff:Model", written either as an attribute or as an element
+func xmpProperty(xmp, name string) string {
+	quoted := regexp.QuoteMeta(name)
+	attr := regexp.MustCompile(`\s` + quoted + `\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	if m := attr.FindStringSubmatch(xmp); m != nil {
+		return strings.TrimSpace(m[1] + m[2])
+	}
+	elem := regexp.MustCompile(`(?s)<` + quoted + `>([^<]*)</` + quoted + `>`)
+	if m := elem.FindStringSubmatch(xmp); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+// parseXMP fills fields of info that EXIF did not provide
+func parseXMP(data []byte, info *Info) {
+	xmp := string(data)
+
+	if info.CameraMake == "" {
+		info.CameraMake = xmpProperty(xmp, "tiff:Make")
+	}
+	if info.CameraModel == "" {
+		info.CameraModel = xmpProperty(xmp, "tiff:Model")
+	}
+	if info.Orientation == 0 {
+		if v, err := strconv.Atoi(xmpProperty(xmp, "tiff:Orientation")); err == nil && v >= 1 && v <= 8 {
+			info.Orientation = v
+		}
+	}
+	if info.TakenAt == nil {
+		for _, name := range []string{"exif:DateTimeOriginal", "photoshop:DateCreated", "xmp:CreateDate"} {
+			if t, ok := parseXMPTime(xmpProperty(xmp, name)); ok {
+				info.TakenAt = &t
+				break
+			}
+		}
+	}
+	if xmpGPSAttr.MatchString(xmp) || xmpGPSElement.MatchString(xmp) {
+		info.HasGPS = true
+	}
+}
+
+// parseXMPTime parses the ISO 8601 variants allowed in XMP dates
+func parseXMPTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04Z07:00", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// stripXMPGPS blanks GPS properties in an XMP packet in place. Matches are
+// overwritten with spaces so the packet keeps its length.
+func stripXMPGPS(data []byte) bool {
+	stripped := false
+	for _, re := range []*regexp.Regexp{xmpGPSAttr, xmpGPSElement} {
+		for _, loc := range re.FindAllIndex(data, -1) {
+			for i := loc[0]; i < loc[1]; i++ {
+				data[i] = ' '
+			}
+			stripped = true
+		}
+	}
+	return stripped
+}
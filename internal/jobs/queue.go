@@ -0,0 +1,150 @@
+// Package jobs implements a durable background job queue backed by the
+// "jobs" table in PostgreSQL.
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultMaxAttempts is how often a job is tried before it is marked dead
+const defaultMaxAttempts = 5
+
+// ErrNotRetryable is returned by Retry for jobs that are not dead
+var ErrNotRetryable = errors.New("only dead jobs can be retried")
+
+// Queue enqueues and inspects jobs
+type Queue struct {
+	db *gorm.DB
+}
+
+// NewQueue creates a new job queue
+func NewQueue(db *gorm.DB) *Queue {
+	return &Queue{db: db}
+}
+
+// Option customizes an enqueued job
+type Option func(*models.Job)
+
+// Delay postpones the first run of a job
+func Delay(d time.Duration) Option {
+	return func(j *models.Job) {
+		j.RunAt = time.Now().Add(d).UnixMilli()
+	}
+}
+
+// MaxAttempts overrides how often a job is tried before it is marked dead
+func MaxAttempts(n int) Option {
+	return func(j *models.Job) {
+		j.MaxAttempts = n
+	}
+}
+
+// Enqueue adds a job of the given type. payload is stored as JSON.
+func (q *Queue) Enqueue(jobType string, payload interface{}, opts ...Option) (*models.Job, error) {
+	return q.EnqueueTx(q.db, jobType, payload, opts...)
+}
+
+// EnqueueTx adds a job inside tx, so it is only queued if the surrounding
+// transaction commits
+func (q *Queue) EnqueueTx(tx *gorm.DB, jobType string, payload interface{}, opts ...Option) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     data,
+		Status:      models.JobPending,
+		RunAt:       time.Now().UnixMilli(),
+		MaxAttempts: defaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if err := tx.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// List returns jobs ordered by newest first, optionally filtered by status
+// and type, along with the total number of matching jobs
+func (q *Queue) List(status, jobType string, limit, offset int) ([]models.Job, int64, error) {
+	query := q.db.Model(&models.Job{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []models.Job
+	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts
+func (q *Queue) Retry(jobID uint) (*models.Job, error) {
+	var job models.Job
+	if err := q.db.First(&job, jobID).Error; err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobDead {
+		return nil, ErrNotRetryable
+	}
+
+	result := q.db.Model(&job).Where("status = ?", models.JobDead).Updates(map[string]interface{}{
+		"status":      models.JobPending,
+		"attempts":    0,
+		"run_at":      time.Now().UnixMilli(),
+		"finished_at": nil,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotRetryable
+	}
+
+	if err := q.db.First(&job, jobID).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Stats returns the number of jobs per status
+func (q *Queue) Stats() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := q.db.Model(&models.Job{}).Select("status, count(*) as count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := map[string]int64{
+		models.JobPending:   0,
+		models.JobRunning:   0,
+		models.JobSucceeded: 0,
+		models.JobDead:      0,
+	}
+	for _, r := range rows {
+		stats[r.Status] = r.Count
+	}
+	return stats, nil
+}
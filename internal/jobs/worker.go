@@ -0,0 +1,381 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// pollInterval is how long an idle worker waits before polling again
+	pollInterval = time.Second
+
+	// lockTimeout is how long a running job may go without a heartbeat
+	// before it is considered abandoned and handed to another worker
+	lockTimeout = 5 * time.Minute
+
+	// heartbeatInterval is how often running jobs refresh their lock
+	heartbeatInterval = time.Minute
+
+	// maintenanceInterval is how often abandoned and old jobs are cleaned up
+	maintenanceInterval = time.Minute
+
+	// succeededRetention is how long finished jobs are kept for inspection
+	succeededRetention = 7 * 24 * time.Hour
+
+	// Retry backoff doubles from minBackoff up to maxBackoff
+	minBackoff = 10 * time.Second
+	maxBackoff = time.Hour
+)
+
+// errNoJob is returned by claim when no job is ready to run
+var errNoJob = errors.New("no job ready")
+
+// HandlerFunc runs a job. Returning an error schedules a retry with
+// exponential backoff; wrap it with Permanent to fail the job immediately.
+type HandlerFunc func(ctx context.Context, job *models.Job) error
+
+// permanentError marks a job failure that should not be retried
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job is marked dead without further retries
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// schedule is a job type that is enqueued periodically
+type schedule struct {
+	jobType  string
+	interval time.Duration
+}
+
+// Worker runs jobs from the queue with a fixed number of goroutines
+type Worker struct {
+	db          *gorm.DB
+	queue       *Queue
+	concurrency int
+	id          string
+	handlers    map[string]HandlerFunc
+	schedules   []schedule
+}
+
+// NewWorker creates a worker pool running up to concurrency jobs at once
+func NewWorker(db *gorm.DB, concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	host, _ := os.Hostname()
+	return &Worker{
+		db:          db,
+		queue:       NewQueue(db),
+		concurrency: concurrency,
+		id:          fmt.Sprintf("%s-%d", host, os.Getpid()),
+		handlers:    make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers the handler for a job type. Only job types with a
+// handler are claimed by this worker. Must be called before Run.
+func (w *Worker) Handle(jobType string, fn HandlerFunc) {
+	w.handlers[jobType] = fn
+}
+
+// Every enqueues a job of the given type at the interval, unless one is
+// already waiting or running. Used for periodic maintenance tasks; safe to
+// use from several instances. Must be called before Run.
+func (w *Worker) Every(interval time.Duration, jobType string) {
+	w.schedules = append(w.schedules, schedule{jobType: jobType, interval: interval})
+}
+
+// Run processes jobs until ctx is cancelled, then waits for running jobs
+// to stop. Jobs interrupted by shutdown go back to the queue.
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+
+	for _, s := range w.schedules {
+		wg.Add(1)
+		go func(s schedule) {
+			defer wg.Done()
+			w.schedule(ctx, s)
+		}(s)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.maintain(ctx)
+	}()
+
+	wg.Wait()
+}
+
+// loop claims and runs jobs one at a time
+func (w *Worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := w.claim()
+		if err != nil {
+			if !errors.Is(err, errNoJob) {
+				slog.ErrorContext(ctx, "Failed to claim job", "worker", w.id, "error", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		w.run(ctx, job)
+	}
+}
+
+// types returns the job types this worker has handlers for
+func (w *Worker) types() []string {
+	types := make([]string, 0, len(w.handlers))
+	for t := range w.handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+// claim locks the next ready job and marks it running. SKIP LOCKED lets
+// concurrent workers claim different jobs without waiting on each other.
+func (w *Worker) claim() (*models.Job, error) {
+	if len(w.handlers) == 0 {
+		return nil, errNoJob
+	}
+
+	var job models.Job
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ? AND type IN ?", models.JobPending, now, w.types()).
+			Order("run_at, id").
+			Limit(1).
+			Find(&job)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNoJob
+		}
+
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.LockedAt = &now
+		job.LockedBy = w.id
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": now,
+			"locked_by": w.id,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run executes a claimed job and records the outcome
+func (w *Worker) run(ctx context.Context, job *models.Job) {
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	go w.heartbeat(heartbeatCtx, job)
+
+	err := w.call(ctx, job)
+	stopHeartbeat()
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_at": nil,
+		"locked_by": "",
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Interrupted by shutdown: give the attempt back and let the next
+		// worker pick it up
+		updates["status"] = models.JobPending
+		updates["attempts"] = job.Attempts - 1
+	case err == nil:
+		updates["status"] = models.JobSucceeded
+		updates["finished_at"] = now.UnixMilli()
+		updates["last_error"] = ""
+	case errors.As(err, &permanentError{}) || job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobDead
+		updates["finished_at"] = now.UnixMilli()
+		updates["last_error"] = err.Error()
+		slog.ErrorContext(ctx, "Job failed permanently", "job_id", job.ID, "job_type", job.Type, "attempts", job.Attempts, "error", err)
+	default:
+		updates["status"] = models.JobPending
+		updates["run_at"] = now.Add(backoff(job.Attempts)).UnixMilli()
+		updates["last_error"] = err.Error()
+		slog.WarnContext(ctx, "Job attempt failed", "job_id", job.ID, "job_type", job.Type, "attempt", job.Attempts, "error", err)
+	}
+
+	// A job requeued as abandoned may have been claimed again meanwhile;
+	// the new run owns it then
+	if err := w.owned(job).Updates(updates).Error; err != nil {
+		slog.ErrorContext(ctx, "Failed to update job", "job_id", job.ID, "error", err)
+	}
+}
+
+// call invokes the job's handler, turning panics into errors
+func (w *Worker) call(ctx context.Context, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job type %q", job.Type))
+	}
+	return handler(ctx, job)
+}
+
+// owned selects the job while this run still holds its lock. Loops of one
+// worker share its ID, so the attempt tells their runs apart.
+func (w *Worker) owned(job *models.Job) *gorm.DB {
+	return w.db.Model(&models.Job{}).
+		Where("id = ? AND locked_by = ? AND attempts = ?", job.ID, w.id, job.Attempts)
+}
+
+// heartbeat keeps the job's lock fresh while it runs
+func (w *Worker) heartbeat(ctx context.Context, job *models.Job) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.owned(job).Update("locked_at", time.Now().UnixMilli())
+		}
+	}
+}
+
+// schedule enqueues a periodic job at every interval
+func (w *Worker) schedule(ctx context.Context, s schedule) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		var active int64
+		err := w.db.Model(&models.Job{}).
+			Where("type = ? AND status IN ?", s.jobType, []string{models.JobPending, models.JobRunning}).
+			Count(&active).Error
+		if err == nil && active == 0 {
+			_, err = w.queue.Enqueue(s.jobType, struct{}{})
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to schedule job", "job_type", s.jobType, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maintain requeues jobs abandoned by crashed workers and deletes old
+// succeeded jobs
+func (w *Worker) maintain(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+
+		w.recoverAbandoned(ctx, now)
+
+		if err := w.db.Where("status = ? AND finished_at < ?", models.JobSucceeded, now.Add(-succeededRetention).UnixMilli()).
+			Delete(&models.Job{}).Error; err != nil {
+			slog.WarnContext(ctx, "Failed to delete old jobs", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverAbandoned handles running jobs whose lock expired like a failed
+// attempt: they are retried with backoff, or marked dead once they ran out
+// of attempts, so a job crashing its worker cannot run forever
+func (w *Worker) recoverAbandoned(ctx context.Context, now time.Time) {
+	expired := now.Add(-lockTimeout).UnixMilli()
+
+	var stale []models.Job
+	if err := w.db.Where("status = ? AND locked_at < ?", models.JobRunning, expired).
+		Find(&stale).Error; err != nil {
+		slog.ErrorContext(ctx, "Failed to find abandoned jobs", "error", err)
+		return
+	}
+
+	for _, job := range stale {
+		updates := map[string]interface{}{
+			"locked_at":  nil,
+			"locked_by":  "",
+			"last_error": "worker stopped responding",
+		}
+		if job.Attempts >= job.MaxAttempts {
+			updates["status"] = models.JobDead
+			updates["finished_at"] = now.UnixMilli()
+		} else {
+			updates["status"] = models.JobPending
+			updates["run_at"] = now.Add(backoff(job.Attempts)).UnixMilli()
+		}
+
+		// Skip jobs whose run sent a heartbeat or finished since the query
+		res := w.db.Model(&models.Job{}).
+			Where("id = ? AND status = ? AND attempts = ? AND locked_at < ?", job.ID, models.JobRunning, job.Attempts, expired).
+			Updates(updates)
+		switch {
+		case res.Error != nil:
+			slog.ErrorContext(ctx, "Failed to recover abandoned job", "job_id", job.ID, "error", res.Error)
+		case res.RowsAffected == 0:
+		case updates["status"] == models.JobDead:
+			slog.ErrorContext(ctx, "Abandoned job failed permanently", "job_id", job.ID, "job_type", job.Type, "attempts", job.Attempts)
+		default:
+			slog.WarnContext(ctx, "Requeued abandoned job", "job_id", job.ID, "job_type", job.Type, "attempt", job.Attempts)
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, doubling from
+// minBackoff up to maxBackoff with up to 10% jitter
+func backoff(attempts int) time.Duration {
+	d := maxBackoff
+	if attempts >= 1 && attempts <= 16 {
+		d = min(minBackoff<<(attempts-1), maxBackoff)
+	}
+	return d + rand.N(d/10+1)
+}
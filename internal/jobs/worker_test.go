@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, minBackoff},
+		{2, 2 * minBackoff},
+		{3, 4 * minBackoff},
+		{10, maxBackoff},
+		{100, maxBackoff},
+		{0, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		got := backoff(tt.attempts)
+		if got < tt.want || got > tt.want+tt.want/10 {
+			t.Errorf("backoff(%d) = %v, want %v plus up to 10%% jitter", tt.attempts, got, tt.want)
+		}
+	}
+}
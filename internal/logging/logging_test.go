@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestNew_AddsContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", FormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), 42)
+	logger.DebugContext(ctx, "hidden")
+	logger.InfoContext(ctx, "hello", "media_id", 7)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "hello" || record["request_id"] != "req-1" || record["user_id"] != float64(42) || record["media_id"] != float64(7) {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestNew_RejectsInvalidSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", FormatJSON); err == nil {
+		t.Error("invalid level: expected an error")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("invalid format: expected an error")
+	}
+}
@@ -0,0 +1,109 @@
+package mediaproc
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Variant is one rung of the HLS bitrate ladder
+type Variant struct {
+	Name         string // Directory name of the variant (e.g. "720p")
+	Height       int    // Output height in pixels
+	VideoBitrate int    // kbit/s
+	AudioBitrate int    // kbit/s
+}
+
+// ladder lists the HLS variants from highest to lowest quality
+var ladder = []Variant{
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 128},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 96},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+}
+
+// hlsSegmentSeconds is the target duration of each segment
+const hlsSegmentSeconds = 6
+
+// variantsFor returns the ladder variants that do not upscale a source of
+// the given height. The lowest variant is always included.
+func variantsFor(height int) []Variant {
+	var variants []Variant
+	for _, v := range ladder {
+		if height <= 0 || v.Height <= height {
+			variants = append(variants, v)
+		}
+	}
+	if len(variants) == 0 {
+		variants = append(variants, ladder[len(ladder)-1])
+	}
+	return variants
+}
+
+// TranscodeHLS encodes one variant of the input video as an HLS media
+// playlist (index.m3u8) with MPEG-TS segments in outDir
+func (t Tools) TranscodeHLS(ctx context.Context, input, outDir string, v Variant, hasAudio bool) error {
+	args := []string{
+		"-v", "error",
+		"-y",
+		"-i", input,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("scale=-2:%d", v.Height),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "main",
+		"-b:v", fmt.Sprintf("%dk", v.VideoBitrate),
+		"-maxrate", fmt.Sprintf("%dk", v.VideoBitrate*107/100),
+		"-bufsize", fmt.Sprintf("%dk", v.VideoBitrate*3/2),
+		// Keyframes on segment boundaries so every segment can start playback
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds),
+	}
+	if hasAudio {
+		args = append(args,
+			"-map", "0:a:0",
+			"-c:a", "aac",
+			"-b:a", fmt.Sprintf("%dk", v.AudioBitrate),
+			"-ac", "2",
+		)
+	}
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(outDir, "seg_%04d.ts"),
+		filepath.Join(outDir, "index.m3u8"),
+	)
+
+	cmd := exec.CommandContext(ctx, t.FFmpeg, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed for %s: %w: %s", v.Name, err, out)
+	}
+	return nil
+}
+
+// masterPlaylist builds the HLS master playlist referencing each variant's
+// media playlist. width and height are the displayed source dimensions,
+// used to compute each variant's resolution.
+func masterPlaylist(variants []Variant, width, height int, hasAudio bool) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+
+	for _, v := range variants {
+		bandwidth := v.VideoBitrate
+		if hasAudio {
+			bandwidth += v.AudioBitrate
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", bandwidth*1000)
+		if width > 0 && height > 0 {
+			// scale=-2:H keeps the aspect ratio and rounds the width to even
+			w := (width*v.Height/height + 1) &^ 1
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", w, v.Height)
+		}
+		b.WriteString("\n" + v.Name + "/index.m3u8\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package mediaproc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVariantsFor_DoesNotUpscale(t *testing.T) {
+	variants := variantsFor(720)
+	if len(variants) != 3 || variants[0].Name != "720p" {
+		t.Fatalf("unexpected variants for 720p source: %+v", variants)
+	}
+
+	// Tiny sources still get the lowest rung
+	if variants := variantsFor(240); len(variants) != 1 || variants[0].Name != "360p" {
+		t.Fatalf("unexpected variants for 240p source: %+v", variants)
+	}
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	playlist := masterPlaylist(variantsFor(720), 1280, 720, true)
+
+	if !strings.HasPrefix(playlist, "#EXTM3U\n") {
+		t.Fatalf("playlist missing header: %q", playlist)
+	}
+	want := "#EXT-X-STREAM-INF:BANDWIDTH=2928000,RESOLUTION=1280x720\n720p/index.m3u8\n"
+	if !strings.Contains(playlist, want) {
+		t.Fatalf("playlist missing 720p variant %q:\n%s", want, playlist)
+	}
+	if !strings.Contains(playlist, "RESOLUTION=640x360\n360p/index.m3u8") {
+		t.Fatalf("playlist missing 360p variant:\n%s", playlist)
+	}
+}
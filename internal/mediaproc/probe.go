@@ -0,0 +1,126 @@
+// Package mediaproc runs background processing of uploaded audio and video
+// files using ffprobe and ffmpeg.
+package mediaproc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Tools holds the paths of the external binaries used for processing
+type Tools struct {
+	FFprobe string // ffprobe-compatible binary
+	FFmpeg  string // ffmpeg-compatible binary
+}
+
+// ProbeResult describes the streams of an audio or video file
+type ProbeResult struct {
+	Duration   float64 // Seconds
+	Bitrate    int64   // Bits per second
+	VideoCodec string
+	AudioCodec string
+	Width      int // Displayed width of the first video stream
+	Height     int // Displayed height of the first video stream
+}
+
+// ffprobeOutput is the subset of `ffprobe -print_format json` we read
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string            `json:"codec_type"`
+		CodecName    string            `json:"codec_name"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		Tags         map[string]string `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe inspects a media file with ffprobe
+func (t Tools) Probe(ctx context.Context, path string) (*ProbeResult, error) {
+	cmd := exec.CommandContext(ctx, t.FFprobe,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("ffprobe failed: %s", exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+	return parseProbeOutput(out)
+}
+
+// parseProbeOutput converts ffprobe JSON output into a ProbeResult
+func parseProbeOutput(out []byte) (*ProbeResult, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	result := &ProbeResult{}
+	result.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	result.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "video":
+			if result.VideoCodec != "" {
+				continue
+			}
+			result.VideoCodec = s.CodecName
+			result.Width, result.Height = s.Width, s.Height
+
+			// Phone videos are often stored landscape with a rotation flag
+			rotation, _ := strconv.ParseFloat(s.Tags["rotate"], 64)
+			for _, sd := range s.SideDataList {
+				if sd.Rotation != 0 {
+					rotation = sd.Rotation
+				}
+			}
+			if int(rotation)%180 != 0 {
+				result.Width, result.Height = result.Height, result.Width
+			}
+		case "audio":
+			if result.AudioCodec == "" {
+				result.AudioCodec = s.CodecName
+			}
+		}
+	}
+
+	if result.VideoCodec == "" && result.AudioCodec == "" {
+		return nil, fmt.Errorf("no audio or video streams found")
+	}
+
+	return result, nil
+}
+
+// ExtractFrame writes a single JPEG frame taken at the given offset (in
+// seconds) of the input video to output
+func (t Tools) ExtractFrame(ctx context.Context, input, output string, at float64) error {
+	cmd := exec.CommandContext(ctx, t.FFmpeg,
+		"-v", "error",
+		"-y",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", input,
+		"-frames:v", "1",
+		"-q:v", "3",
+		output,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package mediaproc
+
+import "testing"
+
+func TestParseProbeOutput_RotatedVideo(t *testing.T) {
+	out := []byte(`{
+		"streams": [
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080,
+			 "side_data_list": [{"rotation": -90}]},
+			{"codec_type": "audio", "codec_name": "aac"}
+		],
+		"format": {"duration": "12.480000", "bit_rate": "8123456"}
+	}`)
+
+	result, err := parseProbeOutput(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.VideoCodec != "h264" || result.AudioCodec != "aac" {
+		t.Errorf("unexpected codecs %q/%q", result.VideoCodec, result.AudioCodec)
+	}
+	if result.Width != 1080 || result.Height != 1920 {
+		t.Errorf("expected rotated size 1080x1920, got %dx%d", result.Width, result.Height)
+	}
+	if result.Duration != 12.48 || result.Bitrate != 8123456 {
+		t.Errorf("unexpected duration %v / bitrate %d", result.Duration, result.Bitrate)
+	}
+}
+
+func TestParseProbeOutput_NoStreams(t *testing.T) {
+	if _, err := parseProbeOutput([]byte(`{"streams": [], "format": {}}`)); err == nil {
+		t.Fatal("expected error for file without audio or video streams")
+	}
+}
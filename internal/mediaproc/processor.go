@@ -0,0 +1,350 @@
+package mediaproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/imagemeta"
+	"github.com/ristep/smanzy_backend/internal/jobs"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/gorm"
+)
+
+const (
+	// probeTimeout bounds the time spent probing a single file
+	probeTimeout = 5 * time.Minute
+
+	// hlsTimeout bounds the time spent transcoding a single video
+	hlsTimeout = 2 * time.Hour
+)
+
+// Job types handled by the processor
+const (
+	JobProbe = "media.probe"
+	JobHLS   = "media.hls"
+)
+
+// mediaPayload is the payload of media processing jobs
+type mediaPayload struct {
+	MediaID uint `json:"media_id"`
+}
+
+// errStale is returned when a media record was deleted or its file replaced
+// while it was being processed
+var errStale = errors.New("media changed during processing")
+
+// Processor probes uploaded audio and video files in the background,
+// extracts poster frames for videos and transcodes them to HLS.
+// Work is queued as jobs and run by a jobs.Worker.
+type Processor struct {
+	db    *gorm.DB
+	store *storage.Local
+	blobs *services.BlobService
+	queue *jobs.Queue
+	tools Tools
+}
+
+// NewProcessor creates a new media processor
+func NewProcessor(db *gorm.DB, store *storage.Local, queue *jobs.Queue, tools Tools) *Processor {
+	return &Processor{
+		db:    db,
+		store: store,
+		blobs: services.NewBlobService(db, store),
+		queue: queue,
+		tools: tools,
+	}
+}
+
+// Register adds the processor's job handlers to a worker
+func (p *Processor) Register(w *jobs.Worker) {
+	w.Handle(JobProbe, p.handler(p.Process))
+	w.Handle(JobHLS, p.handler(p.TranscodeHLS))
+}
+
+// handler adapts a media processing step to a job handler
+func (p *Processor) handler(step func(ctx context.Context, mediaID uint) error) jobs.HandlerFunc {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload mediaPayload
+		if err := job.DecodePayload(&payload); err != nil {
+			return jobs.Permanent(err)
+		}
+		// Nothing left to do for deleted or replaced media
+		if err := step(ctx, payload.MediaID); err != nil && !errors.Is(err, errStale) {
+			return err
+		}
+		return nil
+	}
+}
+
+// EnqueueProbe schedules probing of a media record inside tx, so the job
+// only exists if the record is committed
+func (p *Processor) EnqueueProbe(tx *gorm.DB, mediaID uint) error {
+	_, err := p.queue.EnqueueTx(tx, JobProbe, mediaPayload{MediaID: mediaID}, jobs.MaxAttempts(3))
+	return err
+}
+
+// EnqueueHLS schedules HLS transcoding of a probed video inside tx
+func (p *Processor) EnqueueHLS(tx *gorm.DB, mediaID uint) error {
+	_, err := p.queue.EnqueueTx(tx, JobHLS, mediaPayload{MediaID: mediaID}, jobs.MaxAttempts(2))
+	return err
+}
+
+// Process probes a single media record and stores the results
+func (p *Processor) Process(ctx context.Context, mediaID uint) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	var media models.Media
+	if err := p.db.First(&media, mediaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errStale
+		}
+		return err
+	}
+	if media.Type != "video" && media.Type != "audio" {
+		return nil
+	}
+
+	path, err := p.store.Path(media.StoredName)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.tools.Probe(ctx, path)
+	if err != nil {
+		_ = p.update(&media, map[string]interface{}{"probe_status": models.ProbeFailed})
+		return err
+	}
+
+	if media.Type == "video" && result.VideoCodec != "" {
+		// A missing poster does not make the probe itself fail
+		if err := p.createPoster(ctx, &media, path, result.Duration); err != nil && !errors.Is(err, errStale) {
+			slog.WarnContext(ctx, "Failed to extract poster frame", "media_id", media.ID, "error", err)
+		}
+	}
+
+	updates := map[string]interface{}{
+		"probe_status": models.ProbeDone,
+		"duration":     result.Duration,
+		"bitrate":      result.Bitrate,
+		"video_codec":  result.VideoCodec,
+		"audio_codec":  result.AudioCodec,
+	}
+	if result.Width > 0 && result.Height > 0 {
+		updates["width"] = result.Width
+		updates["height"] = result.Height
+	}
+	if media.Type == "video" && result.VideoCodec != "" {
+		updates["hls_status"] = models.HLSPending
+	}
+	if updates["hls_status"] != models.HLSPending {
+		return p.update(&media, updates)
+	}
+
+	// Queue transcoding together with the results it depends on
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := p.updateTx(tx, &media, updates); err != nil {
+			return err
+		}
+		return p.EnqueueHLS(tx, media.ID)
+	})
+}
+
+// update writes processing results unless the file was replaced meanwhile
+func (p *Processor) update(media *models.Media, updates map[string]interface{}) error {
+	return p.updateTx(p.db, media, updates)
+}
+
+// updateTx is update inside a transaction
+func (p *Processor) updateTx(tx *gorm.DB, media *models.Media, updates map[string]interface{}) error {
+	result := tx.Model(&models.Media{}).
+		Where("id = ? AND content_hash = ?", media.ID, media.ContentHash).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errStale
+	}
+	return nil
+}
+
+// createPoster extracts a frame near the start of the video and stores it
+// as the media's poster rendition, replacing any previous poster
+func (p *Processor) createPoster(ctx context.Context, media *models.Media, path string, duration float64) error {
+	tmp, err := os.CreateTemp("", "poster-*.jpg")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	// One second in skips black lead-in frames without overshooting short clips
+	at := 1.0
+	if duration > 0 && duration < 2 {
+		at = duration / 2
+	}
+	if err := p.tools.ExtractFrame(ctx, path, tmp.Name(), at); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	info := imagemeta.Extract(data)
+
+	obj, err := p.store.PutHashed(bytes.NewReader(data), ".jpg")
+	if err != nil {
+		return err
+	}
+
+	var storedName string
+	var orphans []string
+	err = p.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Media{}).Where("id = ? AND content_hash = ?", media.ID, media.ContentHash).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errStale
+		}
+
+		var err error
+		if orphans, err = p.blobs.ReleaseRenditions(tx, media.ID, models.RenditionPoster); err != nil {
+			return err
+		}
+
+		blob, err := p.blobs.Acquire(tx, obj)
+		if err != nil {
+			return err
+		}
+		storedName = blob.StoredName
+
+		return tx.Create(&models.Rendition{
+			MediaID:     media.ID,
+			Kind:        models.RenditionPoster,
+			StoredName:  blob.StoredName,
+			URL:         "/api/media/files/" + blob.StoredName,
+			ContentHash: blob.Hash,
+			MimeType:    "image/jpeg",
+			Size:        blob.Size,
+			Width:       info.Width,
+			Height:      info.Height,
+		}).Error
+	})
+	if err != nil {
+		_ = p.blobs.Discard(obj)
+		return err
+	}
+
+	if storedName != obj.Key {
+		_ = p.blobs.Discard(obj)
+	}
+	for _, name := range orphans {
+		if err := p.blobs.RemoveFile(name); err != nil {
+			slog.WarnContext(ctx, "Failed to delete file", "media_id", media.ID, "file", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// TranscodeHLS encodes a probed video into HLS variants and stores the
+// playlists and segments under models.HLSPrefix
+func (p *Processor) TranscodeHLS(ctx context.Context, mediaID uint) error {
+	ctx, cancel := context.WithTimeout(ctx, hlsTimeout)
+	defer cancel()
+
+	var media models.Media
+	if err := p.db.First(&media, mediaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errStale
+		}
+		return err
+	}
+	if media.Type != "video" || media.VideoCodec == "" {
+		return nil
+	}
+
+	if err := p.update(&media, map[string]interface{}{"hls_status": models.HLSProcessing}); err != nil {
+		return err
+	}
+
+	if err := p.transcodeHLS(ctx, &media); err != nil {
+		_ = p.store.RemoveAll(models.HLSPrefix(media.ID))
+		// Shutting down leaves the record processing; the job is requeued
+		if ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			_ = p.update(&media, map[string]interface{}{"hls_status": models.HLSFailed})
+		}
+		return err
+	}
+
+	if err := p.update(&media, map[string]interface{}{"hls_status": models.HLSReady}); err != nil {
+		// Replaced or deleted while transcoding; the output is stale
+		_ = p.store.RemoveAll(models.HLSPrefix(media.ID))
+		return err
+	}
+	return nil
+}
+
+// transcodeHLS runs ffmpeg for each variant in a temp directory and copies
+// the results into storage
+func (p *Processor) transcodeHLS(ctx context.Context, media *models.Media) error {
+	input, err := p.store.Path(media.StoredName)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "hls-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	hasAudio := media.AudioCodec != ""
+	variants := variantsFor(media.Height)
+	for _, v := range variants {
+		outDir := filepath.Join(tmpDir, v.Name)
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return err
+		}
+		if err := p.tools.TranscodeHLS(ctx, input, outDir, v, hasAudio); err != nil {
+			return err
+		}
+	}
+
+	master := masterPlaylist(variants, media.Width, media.Height, hasAudio)
+	if err := os.WriteFile(filepath.Join(tmpDir, "master.m3u8"), []byte(master), 0644); err != nil {
+		return err
+	}
+
+	// Replace any output of an earlier run
+	prefix := models.HLSPrefix(media.ID)
+	if err := p.store.RemoveAll(prefix); err != nil {
+		return err
+	}
+	return filepath.WalkDir(tmpDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tmpDir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = p.store.Put(prefix+"/"+filepath.ToSlash(rel), f)
+		return err
+	})
+}
@@ -0,0 +1,146 @@
+// Package metrics collects Prometheus metrics for the API and exposes them
+// for scraping.
+//
+// Collectors are package-level so that handlers and middleware can record
+// values without passing them around; Register adds the collectors that need
+// the database before Handler is served.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/ristep/smanzy_backend/internal/jobs"
+)
+
+// namespace prefixes the application's own metrics
+const namespace = "smanzy"
+
+// registry holds all metrics served by Handler
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route", "status"})
+
+	uploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_total",
+		Help:      "Stored uploads and file replacements by media kind.",
+	}, []string{"kind"})
+
+	uploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of stored uploads and file replacements by media kind.",
+	}, []string{"kind"})
+
+	authAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_attempts_total",
+		Help:      "Register, login and refresh attempts by outcome.",
+	}, []string{"action", "result"})
+
+	rateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the authentication rate limit.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, uploads, uploadBytes, authAttempts, rateLimited,
+	)
+}
+
+// Register adds the connection pool statistics of db and the depth of the
+// job queue, both read on every scrape
+func Register(db *sql.DB, queue *jobs.Queue) {
+	registry.MustRegister(
+		collectors.NewDBStatsCollector(db, "postgres"),
+		&jobCollector{queue: queue},
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format. Metrics that
+// fail to collect (e.g. the job queue while the database is down) are left
+// out instead of failing the scrape.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// ObserveRequest records a finished HTTP request. route is the route
+// template (e.g. /api/media/:id), not the requested path, to keep the
+// number of series bounded.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveUpload records a stored upload of the given media kind and size
+func ObserveUpload(kind string, size int64) {
+	uploads.WithLabelValues(kind).Inc()
+	uploadBytes.WithLabelValues(kind).Add(float64(size))
+}
+
+// ObserveAuth records the outcome of an authentication action (register,
+// login or refresh) from its response status
+func ObserveAuth(action string, status int) {
+	result := "success"
+	if status >= 300 {
+		result = "failure"
+	}
+	authAttempts.WithLabelValues(action, result).Inc()
+}
+
+// ObserveRateLimited records a request rejected by the rate limiter
+func ObserveRateLimited() {
+	rateLimited.Inc()
+}
+
+// jobCollector reports the number of jobs per status
+type jobCollector struct {
+	queue *jobs.Queue
+}
+
+var jobsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "jobs"),
+	"Background jobs in the queue by status.",
+	[]string{"status"}, nil,
+)
+
+// Describe implements prometheus.Collector
+func (jc *jobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobsDesc
+}
+
+// Collect implements prometheus.Collector
+func (jc *jobCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := jc.queue.Stats()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(jobsDesc, err)
+		return
+	}
+	for status, count := range stats {
+		ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(count), status)
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_ExposesRecordedMetrics(t *testing.T) {
+	ObserveRequest(http.MethodGet, "/api/media/:id", http.StatusOK, 20*time.Millisecond)
+	ObserveUpload("image", 2048)
+	ObserveAuth("login", http.StatusUnauthorized)
+	ObserveRateLimited()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`smanzy_http_requests_total{method="GET",route="/api/media/:id",status="200"} 1`,
+		`smanzy_http_request_duration_seconds_bucket{method="GET",route="/api/media/:id",status="200",le="0.025"} 1`,
+		`smanzy_upload_bytes_total{kind="image"} 2048`,
+		`smanzy_auth_attempts_total{action="login",result="failure"} 1`,
+		`smanzy_rate_limit_rejections_total 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
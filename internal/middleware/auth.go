@@ -9,6 +9,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/logging"
 	"github.com/ristep/smanzy_backend/internal/models"
 )
 
@@ -18,7 +19,7 @@ func AuthMiddleware(jwtService *auth.JWTService, db *gorm.DB) gin.HandlerFunc {
 		// Extract the token from the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authorization header"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, "Missing authorization header"))
 			c.Abort()
 			return
 		}
@@ -26,7 +27,7 @@ func AuthMiddleware(jwtService *auth.JWTService, db *gorm.DB) gin.HandlerFunc {
 		// Check for Bearer scheme
 		const bearerScheme = "Bearer "
 		if !strings.HasPrefix(authHeader, bearerScheme) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, "Invalid authorization header format"))
 			c.Abort()
 			return
 		}
@@ -36,7 +37,7 @@ func AuthMiddleware(jwtService *auth.JWTService, db *gorm.DB) gin.HandlerFunc {
 		// Validate the token
 		claims, err := jwtService.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, "Invalid or expired token"))
 			c.Abort()
 			return
 		}
@@ -45,36 +46,58 @@ func AuthMiddleware(jwtService *auth.JWTService, db *gorm.DB) gin.HandlerFunc {
 		var user models.User
 		if err := db.Preload("Roles").First(&user, claims.UserID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+				c.JSON(http.StatusUnauthorized, errorBody(c, "User not found"))
 			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				c.JSON(http.StatusInternalServerError, errorBody(c, "Database error"))
 			}
 			c.Abort()
 			return
 		}
 
-		// Attach user and claims to context
+		// Tokens issued before the account was disabled stop working
+		if user.Disabled {
+			c.JSON(http.StatusForbidden, errorBody(c, "Account is disabled"))
+			c.Abort()
+			return
+		}
+
+		// Attach user and claims to context; logs of the request carry the user ID
 		c.Set("user", &user)
 		c.Set("claims", claims)
+		c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), user.ID))
 
 		c.Next()
 	}
 }
 
+// SignedURLOrAuthMiddleware lets requests carrying URL signature parameters
+// through for the handler to verify, and authenticates all others with
+// authMiddleware. It guards files fetched by clients that cannot send an
+// Authorization header, such as native HLS players.
+func SignedURLOrAuthMiddleware(signer *auth.URLSigner, authMiddleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if signer != nil && signer.HasSignature(c.Request.URL.Query()) {
+			c.Next()
+			return
+		}
+		authMiddleware(c)
+	}
+}
+
 // RoleMiddleware checks if the authenticated user has at least one of the required roles
 func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get user from context (should be set by AuthMiddleware)
 		user, exists := c.Get("user")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, "Unauthorized"))
 			c.Abort()
 			return
 		}
 
 		userObj, ok := user.(*models.User)
 		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+			c.JSON(http.StatusInternalServerError, errorBody(c, "Invalid user data"))
 			c.Abort()
 			return
 		}
@@ -94,7 +117,7 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 		}
 
 		if !hasRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.JSON(http.StatusForbidden, errorBody(c, "Insufficient permissions"))
 			c.Abort()
 			return
 		}
@@ -108,7 +131,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, traceparent, tracestate")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
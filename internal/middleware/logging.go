@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/logging"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware takes the request ID from the X-Request-ID header, or
+// generates one when it is missing or malformed, and stores it in the
+// request context for logs and error responses. The ID is echoed in the
+// response header.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs made of letters, digits and . _ : -
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && !strings.ContainsRune("._:-", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random bytes, hex-encoded
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LoggerMiddleware logs every request once it is handled. Server errors are
+// logged as errors and client errors as warnings; health checks and metrics
+// scrapes only at debug level.
+func LoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case strings.HasPrefix(c.Request.URL.Path, "/health") || c.Request.URL.Path == "/metrics":
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)), // -1 when no body was written
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, slog.String("errors", errs))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// RecoveryMiddleware turns panics into 500 responses and logs them with
+// their stack trace. They are also recorded on the request's span, so
+// TracingMiddleware must run before it.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := string(debug.Stack())
+				slog.ErrorContext(c.Request.Context(), "panic while handling request",
+					"panic", fmt.Sprint(r), "stack", stack)
+
+				span := trace.SpanFromContext(c.Request.Context())
+				span.RecordError(fmt.Errorf("panic: %v", r), trace.WithAttributes(semconv.ExceptionStacktrace(stack)))
+				span.SetStatus(codes.Error, "panic")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, errorBody(c, "Internal server error"))
+			}
+		}()
+		c.Next()
+	}
+}
+
+// errorBody is the JSON body of error responses sent by middleware. It
+// carries the request ID so that clients can quote it when reporting
+// problems.
+func errorBody(c *gin.Context, message string) gin.H {
+	body := gin.H{"error": message}
+	if id := logging.RequestID(c.Request.Context()); id != "" {
+		body["request_id"] = id
+	}
+	return body
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/metrics"
+)
+
+// MetricsMiddleware records the count and latency of every request by
+// route template. Requests that match no route share one label so that
+// scanners cannot create unbounded series.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsAuthMiddleware requires "Authorization: Bearer <token>" when token
+// is not empty
+func MetricsAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, errorBody(c, "Invalid metrics token"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitReachedHandler answers requests rejected by the rate limiter and
+// counts them
+func RateLimitReachedHandler(c *gin.Context) {
+	metrics.ObserveRateLimited()
+	c.JSON(http.StatusTooManyRequests, errorBody(c, "Rate limit exceeded. Try again later."))
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultDir is where create writes new migrations, relative to the
+// repository root
+const DefaultDir = "internal/migrate/migrations"
+
+// Usage describes the migrate subcommands
+const Usage = `usage: migrate <command> [arguments]
+
+commands:
+  up             Apply all pending migrations
+  down [n]       Revert the last n applied migrations (default 1)
+  status         List migrations and when they were applied
+  create <name>  Write empty up and down files for a new migration
+                 (-dir sets the directory, default ` + DefaultDir + `)`
+
+// Command runs a migrate subcommand. connect opens the database and is not
+// called by create, which only writes files.
+func Command(ctx context.Context, args []string, connect func() (*gorm.DB, error), out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	if args[0] == "create" {
+		fs := flag.NewFlagSet("create", flag.ContinueOnError)
+		fs.SetOutput(out)
+		dir := fs.String("dir", DefaultDir, "Migrations directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("usage: migrate create [-dir dir] <name>")
+		}
+		up, down, err := Create(*dir, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\nCreated %s\n", up, down)
+		return nil
+	}
+
+	var steps int
+	switch args[0] {
+	case "up", "status":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: migrate %s", args[0])
+		}
+	case "down":
+		steps = 1
+		if len(args) > 2 {
+			return errors.New("usage: migrate down [n]")
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations: %q", args[1])
+			}
+			steps = n
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], Usage)
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	migrator, err := New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+		return err
+
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(out, "No applied migrations")
+		}
+		return err
+
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = time.UnixMilli(*s.AppliedAt).UTC().Format(time.RFC3339)
+			}
+			if s.Missing {
+				applied += " (missing from this binary)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	}
+}
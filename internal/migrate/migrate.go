@@ -0,0 +1,355 @@
+// Package migrate applies versioned SQL migrations to the database.
+//
+// Migrations are pairs of files named NNNN_name.up.sql and NNNN_name.down.sql
+// embedded from the migrations directory. Applied versions are recorded in
+// the schema_migrations table. Each migration runs in its own transaction
+// unless its up (or down) file starts with the line
+//
+//	-- migrate:no-transaction
+//
+// which is needed for statements such as CREATE INDEX CONCURRENTLY. Such a
+// file must hold a single statement, since PostgreSQL runs several sent at
+// once in an implicit transaction; split larger changes into several
+// migrations. It is recorded only after its SQL has run, so if it cannot
+// be recorded it runs again on the next attempt: it must be idempotent (IF
+// NOT EXISTS, IF EXISTS). A PostgreSQL advisory lock keeps concurrent
+// runners, e.g. several instances starting at once, from applying the same
+// migration twice.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// lockKey identifies the advisory lock held while migrating
+const lockKey = 7436290001
+
+// noTransaction is the first line of migrations that must not run in a
+// transaction
+const noTransaction = "-- migrate:no-transaction"
+
+// fileName matches migration file names
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the state of a migration in the database
+type Status struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt *int64 `json:"applied_at"`        // Milliseconds since epoch, nil if pending
+	Missing   bool   `json:"missing,omitempty"` // Applied but unknown to this binary
+}
+
+// schemaMigration is a row of the schema_migrations table
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt int64
+}
+
+// TableName specifies the table name for schemaMigration
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Load reads the migrations in the root of fsys ordered by version. Every
+// migration needs both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs non-empty up and down files", m.Version, m.Name)
+		}
+		for _, sql := range []string{m.Up, m.Down} {
+			if strings.HasPrefix(strings.TrimSpace(sql), noTransaction) && statementCount(sql) > 1 {
+				return nil, fmt.Errorf("migration %d_%s: %s files must hold a single statement", m.Version, m.Name, noTransaction)
+			}
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// statementCount counts the statements of a migration, ignoring line
+// comments. It does not parse strings, so it is only an approximation for
+// SQL with semicolons inside literals.
+func statementCount(sql string) int {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line + "\n")
+	}
+	count := 0
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if strings.TrimSpace(stmt) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New creates a migrator for the migrations embedded in the binary
+func New(db *gorm.DB) (*Migrator, error) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return NewFromFS(db, sub)
+}
+
+// NewFromFS creates a migrator for the migrations in fsys
+func NewFromFS(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies all pending migrations in order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *gorm.DB, applied map[int64]schemaMigration) error {
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			err := run(conn, mig.Up, func(tx *gorm.DB) error {
+				return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now().UnixMilli()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *gorm.DB, applied map[int64]schemaMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			err := run(conn, mig.Down, func(tx *gorm.DB) error {
+				return tx.Where("version = ?", mig.Version).Delete(&schemaMigration{}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns all known migrations and applied ones missing from this
+// binary, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *gorm.DB, applied map[int64]schemaMigration) error {
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if row, ok := applied[mig.Version]; ok {
+				s.AppliedAt = &row.AppliedAt
+				delete(applied, mig.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		for _, row := range applied {
+			appliedAt := row.AppliedAt
+			statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Missing: true})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Version returns the newest applied migration version and the newest
+// version in this binary. Unlike the other methods it takes no lock and
+// does not create the schema_migrations table.
+func (m *Migrator) Version(ctx context.Context) (current, latest int64, err error) {
+	if len(m.migrations) > 0 {
+		latest = m.migrations[len(m.migrations)-1].Version
+	}
+	err = m.db.WithContext(ctx).Raw("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current).Error
+	return current, latest, err
+}
+
+// locked runs fn on a single connection holding the migration lock, with
+// the applied migrations keyed by version
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB, applied map[int64]schemaMigration) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) (err error) {
+		// Start every statement afresh on the pinned connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			// Released even if ctx was cancelled; a closed connection
+			// releases the lock as well
+			if unlockErr := conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; unlockErr != nil && err == nil {
+				err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+			}
+		}()
+
+		if err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name text NOT NULL,
+			applied_at bigint NOT NULL
+		)`).Error; err != nil {
+			return err
+		}
+
+		var rows []schemaMigration
+		if err := conn.Find(&rows).Error; err != nil {
+			return err
+		}
+		applied := make(map[int64]schemaMigration, len(rows))
+		for _, row := range rows {
+			applied[row.Version] = row
+		}
+		return fn(conn, applied)
+	})
+}
+
+// run executes the SQL of a migration and records the result with record,
+// in one transaction unless the SQL opts out. Migrations without a
+// transaction are re-run if recording fails, see the package comment.
+func run(conn *gorm.DB, sql string, record func(tx *gorm.DB) error) error {
+	if strings.HasPrefix(strings.TrimSpace(sql), noTransaction) {
+		if err := conn.Exec(sql).Error; err != nil {
+			return err
+		}
+		if err := record(conn); err != nil {
+			return fmt.Errorf("applied but not recorded, will run again: %w", err)
+		}
+		return nil
+	}
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+		return record(tx)
+	})
+}
+
+// Create writes empty up and down files for a new migration to dir,
+// numbered after the highest existing version, and returns their paths
+func Create(dir, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%04d_%s", version, name)
+	up := filepath.Join(dir, base+".up.sql")
+	down := filepath.Join(dir, base+".down.sql")
+	if err := writeNew(up, "-- "+base+"\n"); err != nil {
+		return "", "", err
+	}
+	if err := writeNew(down, "-- Revert "+base+"\n"); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// writeNew creates a file with the given content, failing if it exists
+func writeNew(name, content string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,256 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX x ON t (c);")},
+		"0002_add_index.down.sql": {Data: []byte("DROP INDEX x;")},
+		"0001_initial.up.sql":     {Data: []byte("CREATE TABLE t (c int);")},
+		"0001_initial.down.sql":   {Data: []byte("DROP TABLE t;")},
+		"README.md":               {Data: []byte("ignored")},
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("Load() = %+v, want versions 1 and 2", migrations)
+	}
+	if migrations[1].Name != "add_index" || migrations[1].Down != "DROP INDEX x;" {
+		t.Errorf("Load() second migration = %+v", migrations[1])
+	}
+
+	invalid := map[string]fstest.MapFS{
+		"missing down": {"0001_a.up.sql": {Data: []byte("SELECT 1;")}},
+		"empty up": {
+			"0001_a.up.sql":   {Data: []byte(" \n")},
+			"0001_a.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"two names": {
+			"0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"bad name": {"1-initial.up.sql": {Data: []byte("SELECT 1;")}},
+		"two statements without a transaction": {
+			"0001_a.up.sql":   {Data: []byte("-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY a ON t (a);\nCREATE INDEX CONCURRENTLY b ON t (b);")},
+			"0001_a.down.sql": {Data: []byte("-- migrate:no-transaction\nDROP INDEX CONCURRENTLY a;")},
+		},
+	}
+	for name, fsys := range invalid {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("Load() with %s: expected an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := Load(sub)
+	if err != nil {
+		t.Fatalf("embedded migrations are invalid: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	up, down, err := Create(dir, "Add User Flags")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(up) != "0001_add_user_flags.up.sql" || filepath.Base(down) != "0001_add_user_flags.down.sql" {
+		t.Errorf("Create() = %s, %s", up, down)
+	}
+
+	up, _, err = Create(dir, "second")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(up) != "0002_second.up.sql" {
+		t.Errorf("Create() = %s, want version 2", up)
+	}
+	if _, err := os.Stat(up); err != nil {
+		t.Errorf("up file not written: %v", err)
+	}
+
+	if _, _, err := Create(dir, "!!!"); err == nil {
+		t.Error("Create() with an empty name: expected an error")
+	}
+}
+
+// baselineColumns is the schema the former AutoMigrate created for the
+// models of the first release, which the initial migration must adopt
+var baselineColumns = map[string][]string{
+	"users":       {"id", "email", "password", "name", "tel", "age", "address", "city", "country", "gender", "email_verified", "created_at", "updated_at", "deleted_at"},
+	"roles":       {"id", "name", "created_at", "updated_at"},
+	"user_roles":  {"user_id", "role_id"},
+	"media":       {"id", "filename", "stored_name", "url", "type", "mime_type", "size", "user_id", "created_at", "updated_at", "deleted_at"},
+	"album":       {"id", "title", "description", "user_id", "created_at", "updated_at", "deleted_at"},
+	"album_media": {"album_id", "media_id"},
+}
+
+func TestInitialMigrationAddsColumnsToBaseline(t *testing.T) {
+	data, err := fs.ReadFile(embedded, "migrations/0001_initial.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql := string(data)
+	createTable := regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`)
+	column := regexp.MustCompile(`(?m)^\s+(\w+) `)
+
+	for _, table := range createTable.FindAllStringSubmatch(sql, -1) {
+		name, body := table[1], table[2]
+		baseline, existed := baselineColumns[name]
+		if !existed {
+			continue // created from scratch
+		}
+		for _, col := range column.FindAllStringSubmatch(body, -1) {
+			if col[1] == "PRIMARY" || slices.Contains(baseline, col[1]) {
+				continue
+			}
+			alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s ", name, col[1])
+			i := strings.Index(sql, alter)
+			if i < 0 {
+				t.Errorf("%s.%s is missing from baseline databases but never added", name, col[1])
+			} else if i > strings.Index(sql, "search_vector") {
+				t.Errorf("%s.%s is added after the search columns that use it", name, col[1])
+			}
+		}
+	}
+}
+
+// Baseline models as the former AutoMigrate created them
+type (
+	baselineUser struct {
+		ID            uint   `gorm:"primaryKey"`
+		Email         string `gorm:"unique;not null"`
+		Password      string `gorm:"not null"`
+		Name          string `gorm:"not null"`
+		Tel           string
+		Age           int
+		Address       string
+		City          string
+		Country       string
+		Gender        string
+		EmailVerified bool           `gorm:"default:false"`
+		Roles         []baselineRole `gorm:"many2many:user_roles;joinForeignKey:UserID;joinReferences:RoleID"`
+		CreatedAt     int64          `gorm:"autoCreateTime:milli"`
+		UpdatedAt     int64          `gorm:"autoUpdateTime:milli"`
+		DeletedAt     gorm.DeletedAt `gorm:"index"`
+	}
+	baselineRole struct {
+		ID        uint   `gorm:"primaryKey"`
+		Name      string `gorm:"unique;not null"`
+		CreatedAt int64  `gorm:"autoCreateTime:milli"`
+		UpdatedAt int64  `gorm:"autoUpdateTime:milli"`
+	}
+	baselineMedia struct {
+		ID         uint   `gorm:"primaryKey"`
+		Filename   string `gorm:"not null"`
+		StoredName string `gorm:"not null"`
+		URL        string `gorm:"not null"`
+		Type       string
+		MimeType   string
+		Size       int64
+		UserID     uint
+		UploadedBy baselineUser    `gorm:"foreignKey:UserID"`
+		Albums     []baselineAlbum `gorm:"many2many:album_media;joinForeignKey:MediaID;joinReferences:AlbumID"`
+		CreatedAt  int64           `gorm:"autoCreateTime:milli"`
+		UpdatedAt  int64           `gorm:"autoUpdateTime:milli"`
+		DeletedAt  gorm.DeletedAt  `gorm:"index"`
+	}
+	baselineAlbum struct {
+		ID          uint   `gorm:"primaryKey"`
+		Title       string `gorm:"not null"`
+		Description string
+		UserID      uint
+		Creator     baselineUser   `gorm:"foreignKey:UserID"`
+		CreatedAt   int64          `gorm:"autoCreateTime:milli"`
+		UpdatedAt   int64          `gorm:"autoUpdateTime:milli"`
+		DeletedAt   gorm.DeletedAt `gorm:"index"`
+	}
+)
+
+func (baselineUser) TableName() string  { return "users" }
+func (baselineRole) TableName() string  { return "roles" }
+func (baselineMedia) TableName() string { return "media" }
+func (baselineAlbum) TableName() string { return "album" }
+
+// TestUpFromBaselineSchema migrates a database created by the former
+// AutoMigrate. It needs PostgreSQL: set TEST_DB_DSN to run it. The test
+// works in a schema of its own and drops it afterwards.
+func TestUpFromBaselineSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection, so that the search path applies to every statement
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if err := db.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+	if err := db.Exec("SET search_path TO " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&baselineUser{}, &baselineRole{}, &baselineMedia{}, &baselineAlbum{}); err != nil {
+		t.Fatalf("failed to create the baseline schema: %v", err)
+	}
+	if err := db.Exec("INSERT INTO media (filename, stored_name, url) VALUES ('a.jpg', 'a.jpg', '/a.jpg')").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Up() on the baseline schema: %v", err)
+	}
+
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM media WHERE search_vector @@ to_tsquery('simple', 'a') AND title IS NULL AND tag_names IS NULL").
+		Scan(&count).Error; err != nil || count != 1 {
+		t.Errorf("migrated media: count = %d, err = %v", count, err)
+	}
+	if err := db.Exec("UPDATE users SET strip_gps = true, disabled = false").Error; err != nil {
+		t.Errorf("migrated users: %v", err)
+	}
+}
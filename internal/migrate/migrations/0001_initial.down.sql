@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS media_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS media_versions;
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS renditions;
+DROP TABLE IF EXISTS blobs;
+DROP TABLE IF EXISTS album_media;
+DROP TABLE IF EXISTS album;
+DROP TABLE IF EXISTS media;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
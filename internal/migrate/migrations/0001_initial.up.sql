@@ -0,0 +1,207 @@
+-- Baseline schema. Every statement is idempotent so that databases created
+-- by the former AutoMigrate-based -migrate flag can adopt migrations. Such
+-- databases may lack columns added since, so those are added separately
+-- before anything refers to them.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    email text NOT NULL CONSTRAINT uni_users_email UNIQUE,
+    password text NOT NULL,
+    name text NOT NULL,
+    tel text,
+    age bigint,
+    address text,
+    city text,
+    country text,
+    gender text,
+    email_verified boolean DEFAULT false,
+    strip_gps boolean DEFAULT false,
+    created_at bigint,
+    updated_at bigint,
+    deleted_at timestamptz
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS strip_gps boolean DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL CONSTRAINT uni_roles_name UNIQUE,
+    created_at bigint,
+    updated_at bigint
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id bigint NOT NULL CONSTRAINT fk_user_roles_user REFERENCES users (id),
+    role_id bigint NOT NULL CONSTRAINT fk_user_roles_role REFERENCES roles (id),
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    filename text NOT NULL,
+    stored_name text NOT NULL,
+    url text NOT NULL,
+    title varchar(200),
+    description text,
+    alt_text varchar(1000),
+    credit varchar(200),
+    license varchar(32),
+    type text,
+    mime_type text,
+    size bigint,
+    content_hash varchar(64),
+    width bigint,
+    height bigint,
+    orientation bigint,
+    taken_at bigint,
+    camera_make text,
+    camera_model text,
+    probe_status text,
+    duration decimal,
+    bitrate bigint,
+    video_codec text,
+    audio_codec text,
+    hls_status text,
+    tag_names text,
+    user_id bigint CONSTRAINT fk_media_uploaded_by REFERENCES users (id),
+    created_at bigint,
+    updated_at bigint,
+    deleted_at timestamptz
+);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS title varchar(200);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS description text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS alt_text varchar(1000);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS credit varchar(200);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS license varchar(32);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS content_hash varchar(64);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS width bigint;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS height bigint;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS orientation bigint;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS taken_at bigint;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS camera_make text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS camera_model text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS probe_status text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS duration decimal;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS bitrate bigint;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS video_codec text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS audio_codec text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS hls_status text;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS tag_names text;
+CREATE INDEX IF NOT EXISTS idx_media_content_hash ON media (content_hash);
+CREATE INDEX IF NOT EXISTS idx_media_deleted_at ON media (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_media_created_at_id ON media (created_at, id);
+
+CREATE TABLE IF NOT EXISTS album (
+    id bigserial PRIMARY KEY,
+    title text NOT NULL,
+    description text,
+    user_id bigint CONSTRAINT fk_album_creator REFERENCES users (id),
+    created_at bigint,
+    updated_at bigint,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_album_deleted_at ON album (deleted_at);
+
+CREATE TABLE IF NOT EXISTS album_media (
+    album_id bigint NOT NULL CONSTRAINT fk_album_media_album REFERENCES album (id),
+    media_id bigint NOT NULL CONSTRAINT fk_album_media_media REFERENCES media (id),
+    PRIMARY KEY (album_id, media_id)
+);
+
+CREATE TABLE IF NOT EXISTS blobs (
+    hash varchar(64) PRIMARY KEY,
+    stored_name text NOT NULL,
+    size bigint,
+    ref_count bigint NOT NULL DEFAULT 0,
+    created_at bigint,
+    updated_at bigint
+);
+
+CREATE TABLE IF NOT EXISTS renditions (
+    id bigserial PRIMARY KEY,
+    media_id bigint NOT NULL CONSTRAINT fk_media_renditions REFERENCES media (id),
+    kind text NOT NULL,
+    stored_name text NOT NULL,
+    url text NOT NULL,
+    content_hash varchar(64),
+    mime_type text,
+    size bigint,
+    width bigint,
+    height bigint,
+    created_at bigint,
+    updated_at bigint
+);
+CREATE INDEX IF NOT EXISTS idx_renditions_media_id ON renditions (media_id);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id bigserial PRIMARY KEY,
+    type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    run_at bigint NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    max_attempts bigint NOT NULL DEFAULT 5,
+    last_error text,
+    locked_at bigint,
+    locked_by text,
+    finished_at bigint,
+    created_at bigint,
+    updated_at bigint
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs (type);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs (status, run_at);
+
+CREATE TABLE IF NOT EXISTS media_versions (
+    id bigserial PRIMARY KEY,
+    media_id bigint NOT NULL,
+    version bigint NOT NULL,
+    filename text NOT NULL,
+    stored_name text NOT NULL,
+    url text NOT NULL,
+    type text,
+    mime_type text,
+    size bigint,
+    content_hash varchar(64),
+    width bigint,
+    height bigint,
+    orientation bigint,
+    taken_at bigint,
+    camera_make text,
+    camera_model text,
+    created_at bigint
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_versions_media_version ON media_versions (media_id, version);
+CREATE INDEX IF NOT EXISTS idx_media_versions_content_hash ON media_versions (content_hash);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    name varchar(64) NOT NULL,
+    created_at bigint
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
+
+CREATE TABLE IF NOT EXISTS media_tags (
+    media_id bigint NOT NULL CONSTRAINT fk_media_tags_media REFERENCES media (id),
+    tag_id bigint NOT NULL CONSTRAINT fk_media_tags_tag REFERENCES tags (id),
+    PRIMARY KEY (media_id, tag_id)
+);
+
+-- Full-text search (see internal/search). The columns are rebuilt so that
+-- databases with an older search expression end up with the current one.
+ALTER TABLE media DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE media ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', regexp_replace(coalesce(filename, ''), '[._/-]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(tag_names, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(alt_text, '')), 'C') ||
+    setweight(to_tsvector('simple', coalesce(credit, '')), 'C')
+) STORED;
+CREATE INDEX idx_media_search_vector ON media USING GIN (search_vector);
+
+ALTER TABLE album DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE album ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX idx_album_search_vector ON album USING GIN (search_vector);
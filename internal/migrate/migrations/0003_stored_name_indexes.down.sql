@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_blobs_stored_name;
+DROP INDEX IF EXISTS idx_media_versions_stored_name;
+DROP INDEX IF EXISTS idx_renditions_stored_name;
+DROP INDEX IF EXISTS idx_media_stored_name;
@@ -0,0 +1,5 @@
+-- File requests look up the records referring to a stored name
+CREATE INDEX IF NOT EXISTS idx_media_stored_name ON media (stored_name);
+CREATE INDEX IF NOT EXISTS idx_renditions_stored_name ON renditions (stored_name);
+CREATE INDEX IF NOT EXISTS idx_media_versions_stored_name ON media_versions (stored_name);
+CREATE INDEX IF NOT EXISTS idx_blobs_stored_name ON blobs (stored_name);
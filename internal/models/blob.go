@@ -0,0 +1,19 @@
+package models
+
+// Blob is a content-addressed file in storage.
+// Identical uploads share a single blob; RefCount tracks how many records
+// point at it so the file is only removed when the last reference goes away.
+type Blob struct {
+	Hash       string `gorm:"primaryKey;size:64" json:"hash"`    // Hex-encoded SHA-256 of the content
+	StoredName string `gorm:"not null;index" json:"stored_name"` // Name of the file in storage
+	Size       int64  `json:"size"`                              // File size in bytes
+	RefCount   int64  `gorm:"not null;default:0" json:"ref_count"`
+
+	CreatedAt int64 `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt int64 `gorm:"autoUpdateTime:milli" json:"updated_at"`
+}
+
+// TableName specifies the table name for Blob
+func (Blob) TableName() string {
+	return "blobs"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "encoding/json"
+
+// Job statuses
+const (
+	JobPending   = "pending"   // Waiting to run (possibly after a failed attempt)
+	JobRunning   = "running"   // Claimed by a worker
+	JobSucceeded = "succeeded" // Finished successfully
+	JobDead      = "dead"      // Failed permanently or ran out of attempts
+)
+
+// Job is a unit of background work stored in PostgreSQL.
+// Workers claim pending jobs with SELECT ... FOR UPDATE SKIP LOCKED, so
+// several workers (or API instances) can share the same queue.
+type Job struct {
+	ID      uint            `gorm:"primaryKey" json:"id"`
+	Type    string          `gorm:"not null;index" json:"type"`
+	Payload json.RawMessage `gorm:"type:jsonb;not null" json:"payload"`
+
+	Status      string `gorm:"not null;default:pending;index:idx_jobs_status_run_at,priority:1" json:"status"`
+	RunAt       int64  `gorm:"not null;index:idx_jobs_status_run_at,priority:2" json:"run_at"` // Earliest start, milliseconds since epoch
+	Attempts    int    `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int    `gorm:"not null;default:5" json:"max_attempts"`
+	LastError   string `json:"last_error"`
+
+	// LockedAt is refreshed while a worker runs the job; running jobs whose
+	// lock goes stale are handed to another worker
+	LockedAt   *int64 `json:"locked_at"`
+	LockedBy   string `json:"locked_by"`
+	FinishedAt *int64 `json:"finished_at"`
+
+	CreatedAt int64 `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt int64 `gorm:"autoUpdateTime:milli" json:"updated_at"`
+}
+
+// TableName specifies the table name for Job
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// DecodePayload unmarshals the job payload into v
+func (j *Job) DecodePayload(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
@@ -1,19 +1,62 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
 
 // Media represents a media file uploaded to the system
 // It tracks file metadata and links to the physical file storage.
 type Media struct {
-	ID         uint   `gorm:"primaryKey" json:"id"`
-	Filename   string `gorm:"not null" json:"filename"`    // Original name of the file
-	StoredName string `gorm:"not null" json:"stored_name"` // Unique name on disk (to prevent overwrites)
-	URL        string `gorm:"not null" json:"url"`         // Public URL to access the file
+	ID         uint   `gorm:"primaryKey;index:idx_media_created_at_id,priority:2" json:"id"`
+	Filename   string `gorm:"not null" json:"filename"`          // Original name of the file
+	StoredName string `gorm:"not null;index" json:"stored_name"` // Unique name on disk (to prevent overwrites)
+	URL        string `gorm:"not null" json:"url"`               // Public URL to access the file
+
+	// Descriptive metadata edited by the owner, used for galleries and attribution
+	Title       string `gorm:"size:200" json:"title"`
+	Description string `gorm:"type:text" json:"description"` // Caption or longer description
+	AltText     string `gorm:"size:1000" json:"alt_text"`    // Accessibility text for images
+	Credit      string `gorm:"size:200" json:"credit"`       // Author or source to attribute
+	License     string `gorm:"size:32" json:"license"`       // One of Licenses, empty if unspecified
 
 	Type     string `json:"type"`      // General category (e.g., "image", "video")
 	MimeType string `json:"mime_type"` // Specific MIME type (e.g., "image/jpeg", "application/pdf")
 	Size     int64  `json:"size"`      // File size in bytes
 
+	// ContentHash is the SHA-256 of the file content and the key of its Blob.
+	// Empty for files uploaded before deduplication was introduced.
+	ContentHash string `gorm:"size:64;index" json:"content_hash"`
+
+	// Image metadata extracted from EXIF/XMP on upload (zero when unknown)
+	Width       int    `json:"width"`       // Width in pixels as displayed
+	Height      int    `json:"height"`      // Height in pixels as displayed
+	Orientation int    `json:"orientation"` // EXIF orientation (1-8)
+	TakenAt     *int64 `json:"taken_at"`    // Capture time in milliseconds since epoch
+	CameraMake  string `json:"camera_make"`
+	CameraModel string `json:"camera_model"`
+
+	// Audio/video properties filled in by background probing
+	ProbeStatus string  `json:"probe_status"` // "pending", "done" or "failed"; empty for other types
+	Duration    float64 `json:"duration"`     // Seconds
+	Bitrate     int64   `json:"bitrate"`      // Bits per second
+	VideoCodec  string  `json:"video_codec"`
+	AudioCodec  string  `json:"audio_codec"`
+
+	// HLSStatus tracks adaptive streaming renditions for videos:
+	// "pending", "processing", "ready" or "failed"; empty for other types.
+	// When ready, the stream is served at /api/media/:id/hls/master.m3u8.
+	HLSStatus string `gorm:"column:hls_status" json:"hls_status"`
+
+	// Tags are free-form labels; TagNames mirrors their names for full-text
+	// search and is maintained by the tag service
+	Tags     []Tag  `gorm:"many2many:media_tags;" json:"tags,omitempty"`
+	TagNames string `gorm:"->;type:text" json:"-"`
+
+	// Renditions are derived files such as video poster frames
+	Renditions []Rendition `gorm:"foreignKey:MediaID" json:"renditions,omitempty"`
+
 	// Foreign Keys
 	// UserID links this media file to a specific User
 	UserID uint `json:"user_id"`
@@ -29,11 +72,59 @@ type Media struct {
 	// json:"-" prevents endless recursion when listing media
 	Albums []Album `gorm:"many2many:album_media;" json:"-"`
 
-	CreatedAt int64          `gorm:"autoCreateTime:milli" json:"created_at"`
+	// Listings are paginated by (created_at, id)
+	CreatedAt int64          `gorm:"autoCreateTime:milli;index:idx_media_created_at_id,priority:1" json:"created_at"`
 	UpdatedAt int64          `gorm:"autoUpdateTime:milli" json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Licenses are the accepted values of Media.License
+var Licenses = []string{
+	"all-rights-reserved",
+	"CC0-1.0",
+	"CC-BY-4.0",
+	"CC-BY-SA-4.0",
+	"CC-BY-ND-4.0",
+	"CC-BY-NC-4.0",
+	"CC-BY-NC-SA-4.0",
+	"CC-BY-NC-ND-4.0",
+	"public-domain",
+}
+
+// IsValidLicense reports whether license is empty or one of Licenses
+func IsValidLicense(license string) bool {
+	if license == "" {
+		return true
+	}
+	for _, l := range Licenses {
+		if l == license {
+			return true
+		}
+	}
+	return false
+}
+
+// Probe statuses
+const (
+	ProbePending = "pending"
+	ProbeDone    = "done"
+	ProbeFailed  = "failed"
+)
+
+// HLS transcoding statuses
+const (
+	HLSPending    = "pending"
+	HLSProcessing = "processing"
+	HLSReady      = "ready"
+	HLSFailed     = "failed"
+)
+
+// HLSPrefix returns the storage key prefix holding a media's HLS playlists
+// and segments
+func HLSPrefix(mediaID uint) string {
+	return fmt.Sprintf("hls/%d", mediaID)
+}
+
 // TableName specifies the table name for Media
 func (Media) TableName() string {
 	return "media"
@@ -0,0 +1,30 @@
+package models
+
+// Rendition kinds
+const (
+	RenditionPoster = "poster" // Still frame representing a video
+)
+
+// Rendition is a file derived from a Media upload, such as a video poster
+// frame. Its content is stored as a Blob and holds a reference to it.
+type Rendition struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	MediaID uint   `gorm:"not null;index" json:"media_id"`
+	Kind    string `gorm:"not null" json:"kind"`
+
+	StoredName  string `gorm:"not null;index" json:"stored_name"`
+	URL         string `gorm:"not null" json:"url"`
+	ContentHash string `gorm:"size:64" json:"-"`
+	MimeType    string `json:"mime_type"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+
+	CreatedAt int64 `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt int64 `gorm:"autoUpdateTime:milli" json:"updated_at"`
+}
+
+// TableName specifies the table name for Rendition
+func (Rendition) TableName() string {
+	return "renditions"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the maximum length of a tag name in characters
+const MaxTagLength = 64
+
+// ErrInvalidTag is returned for tag names that are empty, too long or
+// contain a comma (used to separate tags in query parameters)
+var ErrInvalidTag = errors.New("tags must be 1-64 characters and must not contain commas")
+
+// Tag is a free-form label attached to media. Tag names are shared between
+// users and stored normalized (see NormalizeTag).
+type Tag struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"size:64;not null;uniqueIndex" json:"name"`
+	CreatedAt int64  `gorm:"autoCreateTime:milli" json:"created_at"`
+}
+
+// TableName specifies the table name for Tag
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// NormalizeTag lowercases a tag name and collapses whitespace
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" || utf8.RuneCountInString(name) > MaxTagLength || strings.Contains(name, ",") {
+		return "", ErrInvalidTag
+	}
+	return name, nil
+}
@@ -27,6 +27,13 @@ type User struct {
 	// gorm:"default:false" sets the database column default value to false
 	EmailVerified bool `gorm:"default:false" json:"email_verified"`
 
+	// Disabled accounts cannot sign in or use their tokens
+	Disabled bool `gorm:"not null;default:false" json:"disabled"`
+
+	// StripGPS removes location data from photos this user uploads,
+	// in addition to the server-wide MEDIA_STRIP_GPS setting
+	StripGPS bool `gorm:"default:false" json:"strip_gps"`
+
 	// Roles represents a Many-to-Many relationship
 	// A user can have multiple roles, and a role can belong to multiple users
 	// "many2many:user_roles" tells GORM to create a join table named "user_roles"
@@ -42,6 +49,9 @@ type User struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// MinPasswordLength is the minimum length of user passwords
+const MinPasswordLength = 8
+
 // TableName specifies the table name for User
 // By default GORM plurals struct names (User -> users), but explicit naming is safe.
 func (User) TableName() string {
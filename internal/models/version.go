@@ -0,0 +1,79 @@
+package models
+
+// MediaVersion is a previous file of a Media record, kept when the file is
+// replaced so it can be restored later. Like Media, it holds a reference to
+// the Blob of its content (none for files stored before deduplication).
+type MediaVersion struct {
+	ID      uint `gorm:"primaryKey" json:"id"`
+	MediaID uint `gorm:"not null;uniqueIndex:idx_media_versions_media_version,priority:1" json:"media_id"`
+	Version int  `gorm:"not null;uniqueIndex:idx_media_versions_media_version,priority:2" json:"version"` // Increasing per media record
+
+	Filename    string `gorm:"not null" json:"filename"`
+	StoredName  string `gorm:"not null;index" json:"stored_name"`
+	URL         string `gorm:"not null" json:"url"`
+	Type        string `json:"type"`
+	MimeType    string `json:"mime_type"`
+	Size        int64  `json:"size"`
+	ContentHash string `gorm:"size:64;index" json:"content_hash"`
+
+	// Image metadata of the file, restored together with it. Audio and video
+	// are probed again after a restore.
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Orientation int    `json:"orientation"`
+	TakenAt     *int64 `json:"taken_at"`
+	CameraMake  string `json:"camera_make"`
+	CameraModel string `json:"camera_model"`
+
+	CreatedAt int64 `gorm:"autoCreateTime:milli" json:"created_at"` // When the file was replaced
+}
+
+// TableName specifies the table name for MediaVersion
+func (MediaVersion) TableName() string {
+	return "media_versions"
+}
+
+// NewMediaVersion captures the current file of media as a version
+func NewMediaVersion(media *Media, version int) *MediaVersion {
+	return &MediaVersion{
+		MediaID:     media.ID,
+		Version:     version,
+		Filename:    media.Filename,
+		StoredName:  media.StoredName,
+		URL:         media.URL,
+		Type:        media.Type,
+		MimeType:    media.MimeType,
+		Size:        media.Size,
+		ContentHash: media.ContentHash,
+		Width:       media.Width,
+		Height:      media.Height,
+		Orientation: media.Orientation,
+		TakenAt:     media.TakenAt,
+		CameraMake:  media.CameraMake,
+		CameraModel: media.CameraModel,
+	}
+}
+
+// Apply makes the version's file the current file of media, clearing
+// properties derived from the previous file. The media's filename is kept.
+func (v *MediaVersion) Apply(media *Media) {
+	media.StoredName = v.StoredName
+	media.URL = v.URL
+	media.Type = v.Type
+	media.MimeType = v.MimeType
+	media.Size = v.Size
+	media.ContentHash = v.ContentHash
+	media.Width = v.Width
+	media.Height = v.Height
+	media.Orientation = v.Orientation
+	media.TakenAt = v.TakenAt
+	media.CameraMake = v.CameraMake
+	media.CameraModel = v.CameraModel
+
+	media.Duration, media.Bitrate = 0, 0
+	media.VideoCodec, media.AudioCodec = "", ""
+	media.ProbeStatus, media.HLSStatus = "", ""
+	if v.Type == "video" || v.Type == "audio" {
+		media.ProbeStatus = ProbePending
+	}
+}
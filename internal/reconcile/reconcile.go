@@ -0,0 +1,310 @@
+// Package reconcile finds inconsistencies between the upload directory and
+// the database: files no record refers to, records whose files are missing
+// and blobs whose reference counts drifted.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/jobs"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobReconcile is the job type of periodic in-process reconciliation
+const JobReconcile = "maintenance.reconcile"
+
+// DefaultMinAge is how old an unreferenced file must be before it is
+// considered orphaned. Uploads are written to disk before their record is
+// committed, so younger files may still be claimed.
+const DefaultMinAge = time.Hour
+
+// Options control a reconciliation run
+type Options struct {
+	Fix    bool          // Remove orphaned files and correct blob reference counts
+	MinAge time.Duration // Ignore unreferenced files younger than this
+}
+
+// OrphanFile is a stored file no record refers to
+type OrphanFile struct {
+	Key     string `json:"key"`
+	Size    int64  `json:"size"`
+	Removed bool   `json:"removed"`
+}
+
+// MissingFile is a record whose file is not in the store
+type MissingFile struct {
+	Table string `json:"table"`
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+}
+
+// BlobMismatch is a blob whose stored reference count differs from the
+// number of records using its content
+type BlobMismatch struct {
+	Hash     string `json:"hash"`
+	RefCount int64  `json:"ref_count"`
+	Actual   int64  `json:"actual"`
+	Fixed    bool   `json:"fixed"`
+}
+
+// Report is the outcome of a reconciliation run
+type Report struct {
+	OrphanFiles   []OrphanFile   `json:"orphan_files"`
+	MissingFiles  []MissingFile  `json:"missing_files"`
+	BlobMismatch  []BlobMismatch `json:"blob_mismatch"`
+	ReclaimedSize int64          `json:"reclaimed_size"`
+}
+
+// Summary returns a one-line description of the report
+func (r *Report) Summary() string {
+	removed := 0
+	for _, f := range r.OrphanFiles {
+		if f.Removed {
+			removed++
+		}
+	}
+	return fmt.Sprintf("%d orphaned files (%d removed, %d bytes), %d records with missing files, %d blobs with wrong reference counts",
+		len(r.OrphanFiles), removed, r.ReclaimedSize, len(r.MissingFiles), len(r.BlobMismatch))
+}
+
+// Reconciler compares the store with the database
+type Reconciler struct {
+	db    *gorm.DB
+	store *storage.Local
+}
+
+// NewReconciler creates a new reconciler
+func NewReconciler(db *gorm.DB, store *storage.Local) *Reconciler {
+	return &Reconciler{db: db, store: store}
+}
+
+// Register adds the periodic reconciliation job handler to a worker
+func (r *Reconciler) Register(w *jobs.Worker, opts Options) {
+	w.Handle(JobReconcile, func(ctx context.Context, job *models.Job) error {
+		report, err := r.Run(ctx, opts)
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "Reconciliation finished", "summary", report.Summary())
+		return nil
+	})
+}
+
+// references holds the storage keys the database refers to
+type references struct {
+	files map[string]bool // Top-level stored names
+	hls   map[uint]bool   // Media IDs that may have HLS output
+}
+
+// Run checks the store against the database and, with opts.Fix, repairs
+// what can be repaired safely. Records with missing files are only reported.
+func (r *Reconciler) Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.MinAge <= 0 {
+		opts.MinAge = DefaultMinAge
+	}
+	report := &Report{}
+
+	// Fix reference counts first so blobs nobody uses release their files
+	if err := r.checkBlobs(ctx, opts, report); err != nil {
+		return nil, err
+	}
+
+	refs, err := r.references(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-opts.MinAge)
+	err = r.store.Walk(func(key string, info fs.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if refs.referenced(key) || info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		orphan := OrphanFile{Key: key, Size: info.Size()}
+		if opts.Fix {
+			if err := r.store.Remove(key); err != nil {
+				slog.WarnContext(ctx, "Failed to delete orphaned file", "file", key, "error", err)
+			} else {
+				orphan.Removed = true
+				report.ReclaimedSize += info.Size()
+			}
+		}
+		report.OrphanFiles = append(report.OrphanFiles, orphan)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// references loads the keys referenced by records and reports records whose
+// files are missing
+func (r *Reconciler) references(ctx context.Context, report *Report) (*references, error) {
+	db := r.db.WithContext(ctx)
+	refs := &references{files: make(map[string]bool), hls: make(map[uint]bool)}
+
+	// Media in the trash keep their files until purged
+	var medias []models.Media
+	if err := db.Unscoped().Select("id, stored_name, hls_status").Find(&medias).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range medias {
+		refs.files[m.StoredName] = true
+		if m.HLSStatus == models.HLSProcessing || m.HLSStatus == models.HLSReady {
+			refs.hls[m.ID] = true
+		}
+		r.checkExists(report, "media", strconv.FormatUint(uint64(m.ID), 10), m.StoredName)
+	}
+
+	var renditions []models.Rendition
+	if err := db.Select("id, stored_name").Find(&renditions).Error; err != nil {
+		return nil, err
+	}
+	for _, rd := range renditions {
+		refs.files[rd.StoredName] = true
+		r.checkExists(report, "renditions", strconv.FormatUint(uint64(rd.ID), 10), rd.StoredName)
+	}
+
+	var versions []models.MediaVersion
+	if err := db.Select("id, stored_name").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		refs.files[v.StoredName] = true
+		r.checkExists(report, "media_versions", strconv.FormatUint(uint64(v.ID), 10), v.StoredName)
+	}
+
+	var blobs []models.Blob
+	if err := db.Select("hash, stored_name").Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range blobs {
+		refs.files[b.StoredName] = true
+		r.checkExists(report, "blobs", b.Hash, b.StoredName)
+	}
+
+	return refs, nil
+}
+
+// checkExists records a missing file for the given record
+func (r *Reconciler) checkExists(report *Report, table, id, key string) {
+	if key == "" {
+		return
+	}
+	if _, err := r.store.Stat(key); err != nil {
+		report.MissingFiles = append(report.MissingFiles, MissingFile{Table: table, ID: id, Key: key})
+	}
+}
+
+// checkBlobs compares blob reference counts with the media, renditions and
+// versions using their content
+func (r *Reconciler) checkBlobs(ctx context.Context, opts Options, report *Report) error {
+	db := r.db.WithContext(ctx)
+
+	var hashes []string
+	if err := db.Model(&models.Blob{}).Pluck("hash", &hashes).Error; err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		var mismatch *BlobMismatch
+
+		check := func(tx *gorm.DB) error {
+			var blob models.Blob
+			query := tx
+			if opts.Fix {
+				// Lock the row so concurrent uploads wait for the correction
+				query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+			}
+			if err := query.First(&blob, "hash = ?", hash).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+
+			actual, err := countReferences(tx, hash)
+			if err != nil {
+				return err
+			}
+			if actual == blob.RefCount {
+				return nil
+			}
+
+			mismatch = &BlobMismatch{Hash: hash, RefCount: blob.RefCount, Actual: actual}
+			if !opts.Fix {
+				return nil
+			}
+			// An unused blob's file is then picked up as orphaned by the
+			// directory walk
+			if actual == 0 {
+				if err := tx.Delete(&blob).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Model(&blob).Update("ref_count", actual).Error; err != nil {
+				return err
+			}
+			mismatch.Fixed = true
+			return nil
+		}
+
+		var err error
+		if opts.Fix {
+			err = db.Transaction(check)
+		} else {
+			err = check(db)
+		}
+		if err != nil {
+			return err
+		}
+
+		if mismatch != nil {
+			report.BlobMismatch = append(report.BlobMismatch, *mismatch)
+		}
+	}
+	return nil
+}
+
+// countReferences returns the number of records using a blob's content,
+// including media in the trash
+func countReferences(tx *gorm.DB, hash string) (int64, error) {
+	var total int64
+	for _, model := range []interface{}{&models.Media{}, &models.Rendition{}, &models.MediaVersion{}} {
+		var count int64
+		if err := tx.Unscoped().Model(model).Where("content_hash = ?", hash).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// referenced reports whether a stored file is in use. Temporary upload
+// files are never referenced; HLS output belongs to its media record.
+func (refs *references) referenced(key string) bool {
+	if strings.HasPrefix(path.Base(key), ".upload-") {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(key, "hls/"); ok {
+		dir, _, _ := strings.Cut(rest, "/")
+		id, err := strconv.ParseUint(dir, 10, 64)
+		return err == nil && refs.hls[uint(id)]
+	}
+	return refs.files[key]
+}
@@ -0,0 +1,29 @@
+package reconcile
+
+import "testing"
+
+func TestReferenced(t *testing.T) {
+	refs := &references{
+		files: map[string]bool{"abc.jpg": true},
+		hls:   map[uint]bool{7: true},
+	}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"abc.jpg", true},
+		{"other.jpg", false},
+		{".upload-123", false},
+		{"hls/7/720p/seg_0001.ts", true},
+		{"hls/8/master.m3u8", false},
+		{"hls/x/master.m3u8", false},
+		{"hls/7/.upload-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := refs.referenced(tt.key); got != tt.want {
+			t.Errorf("referenced(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package search implements PostgreSQL full-text search over media and
+// albums.
+//
+// Both tables carry a generated "search_vector" tsvector column with a GIN
+// index, created by the SQL migrations in internal/migrate. The columns are
+// not part of the GORM models. Changing what is searchable means a new
+// migration that drops and re-adds the column with the new expression.
+package search
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Result kinds
+const (
+	KindMedia = "media"
+	KindAlbum = "album"
+)
+
+// textConfig is the text search configuration used for querying; it must
+// match the one of the search columns. "simple" does not stem, which suits
+// filenames and mixed-language titles better than a language-specific
+// configuration.
+const textConfig = "simple"
+
+// Matches in highlights are delimited with control characters by
+// ts_headline and turned into <mark> tags once the text is HTML-escaped
+const (
+	startSel        = "\x02"
+	stopSel         = "\x03"
+	headlineOptions = "StartSel=" + startSel + ", StopSel=" + stopSel + ", MaxFragments=2, MinWords=5, MaxWords=20"
+)
+
+// Query describes a search
+type Query struct {
+	Text      string   // Web search syntax: words, "quoted phrases", -excluded words and OR
+	Kinds     []string // Result kinds to include; all when empty
+	MediaType string   // Only media of this type ("image", "video", "audio", "file")
+	UserID    uint     // Albums are limited to this owner
+	AllAlbums bool     // Include every user's albums (admins)
+	Limit     int
+	Offset    int
+}
+
+// Result is a single search hit
+type Result struct {
+	Kind       string            `json:"kind"`
+	ID         uint              `json:"id"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"` // Matching fields as escaped HTML with matches in <mark>
+	Media      *models.Media     `json:"media,omitempty"`
+	Album      *models.Album     `json:"album,omitempty"`
+}
+
+// Results is a page of search hits
+type Results struct {
+	Results []Result `json:"results"`
+	Total   int64    `json:"total"`
+}
+
+// Service runs searches
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new search service
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// hit is a row of the ranked result list
+type hit struct {
+	Kind string
+	ID   uint
+	Rank float64
+}
+
+// Search returns the hits for q ordered by rank, newest first among equals.
+// Media are visible to everyone, like the public media listing; albums only
+// to their owner unless q.AllAlbums is set.
+func (s *Service) Search(ctx context.Context, q Query) (*Results, error) {
+	db := s.db.WithContext(ctx)
+	results := &Results{Results: []Result{}}
+
+	union, args := rankedQuery(q)
+	if union == "" {
+		return results, nil
+	}
+
+	if err := db.Raw("SELECT count(*) FROM ("+union+") hits", args...).Scan(&results.Total).Error; err != nil {
+		return nil, err
+	}
+	if results.Total == 0 {
+		return results, nil
+	}
+
+	var hits []hit
+	pageArgs := append(append([]interface{}{}, args...), q.Limit, q.Offset)
+	if err := db.Raw(union+" ORDER BY rank DESC, created_at DESC, id DESC LIMIT ? OFFSET ?", pageArgs...).
+		Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+
+	var mediaIDs, albumIDs []uint
+	for _, h := range hits {
+		if h.Kind == KindMedia {
+			mediaIDs = append(mediaIDs, h.ID)
+		} else {
+			albumIDs = append(albumIDs, h.ID)
+		}
+	}
+
+	medias := make(map[uint]*models.Media)
+	if len(mediaIDs) > 0 {
+		var list []models.Media
+		if err := db.Where("id IN ?", mediaIDs).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		for i := range list {
+			medias[list[i].ID] = &list[i]
+		}
+	}
+
+	albums := make(map[uint]*models.Album)
+	if len(albumIDs) > 0 {
+		var list []models.Album
+		if err := db.Where("id IN ?", albumIDs).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		for i := range list {
+			albums[list[i].ID] = &list[i]
+		}
+	}
+
+	highlights, err := s.highlights(db, q.Text, mediaIDs, albumIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range hits {
+		r := Result{Kind: h.Kind, ID: h.ID, Rank: h.Rank, Highlights: highlights[resultKey(h.Kind, h.ID)]}
+		switch h.Kind {
+		case KindMedia:
+			if r.Media = medias[h.ID]; r.Media == nil {
+				continue // deleted meanwhile
+			}
+		case KindAlbum:
+			if r.Album = albums[h.ID]; r.Album == nil {
+				continue
+			}
+		}
+		results.Results = append(results.Results, r)
+	}
+	return results, nil
+}
+
+// rankedQuery builds the UNION of matching media and albums with their
+// rank. It returns an empty query when no kind is selected.
+func rankedQuery(q Query) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+
+	if includes(q.Kinds, KindMedia) {
+		part := `SELECT 'media' AS kind, m.id, ts_rank_cd(m.search_vector, q) AS rank, m.created_at
+			FROM media m, websearch_to_tsquery('` + textConfig + `', ?) q
+			WHERE m.deleted_at IS NULL AND m.search_vector @@ q`
+		args = append(args, q.Text)
+		if q.MediaType != "" {
+			part += " AND m.type = ?"
+			args = append(args, q.MediaType)
+		}
+		parts = append(parts, part)
+	}
+
+	// Filtering by media type excludes albums
+	if includes(q.Kinds, KindAlbum) && q.MediaType == "" {
+		part := `SELECT 'album' AS kind, a.id, ts_rank_cd(a.search_vector, q) AS rank, a.created_at
+			FROM album a, websearch_to_tsquery('` + textConfig + `', ?) q
+			WHERE a.deleted_at IS NULL AND a.search_vector @@ q`
+		args = append(args, q.Text)
+		if !q.AllAlbums {
+			part += " AND a.user_id = ?"
+			args = append(args, q.UserID)
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " UNION ALL "), args
+}
+
+// highlights returns the matching fields of each hit keyed by "kind:id"
+func (s *Service) highlights(db *gorm.DB, text string, mediaIDs, albumIDs []uint) (map[string]map[string]string, error) {
+	out := make(map[string]map[string]string)
+
+	type row struct {
+		ID    uint
+		Field string
+		Text  string
+	}
+	collect := func(kind string, rows []row) {
+		for _, r := range rows {
+			if !strings.Contains(r.Text, startSel) {
+				continue
+			}
+			key := resultKey(kind, r.ID)
+			if out[key] == nil {
+				out[key] = make(map[string]string)
+			}
+			out[key][r.Field] = markHighlight(r.Text)
+		}
+	}
+
+	if len(mediaIDs) > 0 {
+		var rows []row
+		if err := db.Raw(`SELECT m.id, f.field, ts_headline('`+textConfig+`', f.value, q, ?) AS text
+			FROM media m
+			CROSS JOIN websearch_to_tsquery('`+textConfig+`', ?) q
+			CROSS JOIN LATERAL (VALUES
+				('title', m.title),
+				('filename', regexp_replace(m.filename, '[._/-]+', ' ', 'g')),
+				('tags', m.tag_names),
+				('description', m.description),
+				('alt_text', m.alt_text),
+				('credit', m.credit)
+			) f(field, value)
+			WHERE m.id IN ? AND coalesce(f.value, '') <> ''`, headlineOptions, text, mediaIDs).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		collect(KindMedia, rows)
+	}
+
+	if len(albumIDs) > 0 {
+		var rows []row
+		if err := db.Raw(`SELECT a.id, f.field, ts_headline('`+textConfig+`', f.value, q, ?) AS text
+			FROM album a
+			CROSS JOIN websearch_to_tsquery('`+textConfig+`', ?) q
+			CROSS JOIN LATERAL (VALUES ('title', a.title), ('description', a.description)) f(field, value)
+			WHERE a.id IN ? AND coalesce(f.value, '') <> ''`, headlineOptions, text, albumIDs).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		collect(KindAlbum, rows)
+	}
+
+	return out, nil
+}
+
+// markHighlight HTML-escapes a ts_headline result and wraps the matches
+// in <mark> tags
+func markHighlight(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, startSel, "<mark>")
+	return strings.ReplaceAll(text, stopSel, "</mark>")
+}
+
+// resultKey identifies a hit across kinds
+func resultKey(kind string, id uint) string {
+	return kind + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// includes reports whether kind is selected; an empty selection includes all
+func includes(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkHighlight(t *testing.T) {
+	got := markHighlight("<b>summer</b> " + startSel + "trip" + stopSel + " & more")
+	want := "&lt;b&gt;summer&lt;/b&gt; <mark>trip</mark> &amp; more"
+	if got != want {
+		t.Errorf("markHighlight() = %q, want %q", got, want)
+	}
+}
+
+func TestRankedQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		q          Query
+		wantMedia  bool
+		wantAlbums bool
+		wantArgs   int
+	}{
+		{"all kinds", Query{Text: "x", UserID: 1}, true, true, 3},
+		{"admin sees all albums", Query{Text: "x", AllAlbums: true}, true, true, 2},
+		{"media only", Query{Text: "x", Kinds: []string{KindMedia}}, true, false, 1},
+		{"media type excludes albums", Query{Text: "x", MediaType: "image", UserID: 1}, true, false, 2},
+		{"albums only", Query{Text: "x", Kinds: []string{KindAlbum}, UserID: 1}, false, true, 2},
+		{"unknown kind", Query{Text: "x", Kinds: []string{"user"}}, false, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := rankedQuery(tt.q)
+			if got := strings.Contains(query, "FROM media"); got != tt.wantMedia {
+				t.Errorf("media part included = %v, want %v", got, tt.wantMedia)
+			}
+			if got := strings.Contains(query, "FROM album"); got != tt.wantAlbums {
+				t.Errorf("album part included = %v, want %v", got, tt.wantAlbums)
+			}
+			if len(args) != tt.wantArgs {
+				t.Errorf("got %d args, want %d", len(args), tt.wantArgs)
+			}
+			if strings.Count(query, "?") != len(args) {
+				t.Errorf("query has %d placeholders for %d args", strings.Count(query, "?"), len(args))
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package seed creates the data the application needs to run: the built-in
+// roles and, optionally, a bootstrap admin account so that the first admin
+// does not have to be created by editing the database.
+//
+// Seeding is idempotent and safe to run on every start, also from several
+// instances at once.
+package seed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Roles are the roles every installation has
+var Roles = []string{"user", "admin"}
+
+// lockKey identifies the advisory lock held while seeding
+const lockKey = 7436290002
+
+// Admin describes the bootstrap admin account
+type Admin struct {
+	Email    string
+	Password string // Generated when empty
+	Name     string // "Administrator" when empty
+}
+
+// Options configures seeding
+type Options struct {
+	Admin Admin // No admin is bootstrapped when Admin.Email is empty
+
+	// GeneratePassword allows creating the admin with a generated password
+	// when none is configured. Without it such an admin is not created,
+	// since the password could only be shown in the logs.
+	GeneratePassword bool
+}
+
+// Result reports what seeding changed
+type Result struct {
+	RolesCreated  []string
+	AdminCreated  bool
+	AdminPromoted bool // An existing user was given the admin role
+	AdminSkipped  bool // Not created: no password configured or generated
+	AdminDeleted  bool // A deleted user has the admin's email; left alone
+
+	// GeneratedPassword is the password of a newly created admin when none
+	// was configured. It is not stored anywhere else and must be shown to
+	// the operator right away.
+	GeneratedPassword string
+}
+
+// Validate checks the bootstrap admin settings
+func (a Admin) Validate() error {
+	if a.Email == "" {
+		return nil
+	}
+	if !strings.Contains(a.Email, "@") {
+		return fmt.Errorf("invalid bootstrap admin email %q", a.Email)
+	}
+	if a.Password != "" && len(a.Password) < models.MinPasswordLength {
+		return fmt.Errorf("bootstrap admin password must be at least %d characters", models.MinPasswordLength)
+	}
+	return nil
+}
+
+// Run creates missing roles and the bootstrap admin. An existing user with
+// the admin's email keeps their password and is only given the admin role.
+func Run(ctx context.Context, db *gorm.DB, opts Options) (*Result, error) {
+	if err := opts.Admin.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serializes concurrent seeders until the transaction ends
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+
+		for _, name := range Roles {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Role{Name: name})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				result.RolesCreated = append(result.RolesCreated, name)
+			}
+		}
+
+		if opts.Admin.Email == "" {
+			return nil
+		}
+		return seedAdmin(tx, opts, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// seedAdmin creates the bootstrap admin or gives an existing user with its
+// email the admin role. A deleted user with that email is not restored.
+func seedAdmin(tx *gorm.DB, opts Options, result *Result) error {
+	admin := opts.Admin
+	var roles []models.Role
+	if err := tx.Where("name IN ?", Roles).Find(&roles).Error; err != nil {
+		return err
+	}
+
+	var user models.User
+	err := tx.Unscoped().Preload("Roles").Where("email = ?", admin.Email).First(&user).Error
+	if err == nil {
+		if user.DeletedAt.Valid {
+			result.AdminDeleted = true
+			return nil
+		}
+		if user.HasRole("admin") {
+			return nil
+		}
+		if err := tx.Model(&user).Association("Roles").Append(roles); err != nil {
+			return err
+		}
+		result.AdminPromoted = true
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	password := admin.Password
+	if password == "" {
+		if !opts.GeneratePassword {
+			result.AdminSkipped = true
+			return nil
+		}
+		if password, err = GeneratePassword(); err != nil {
+			return err
+		}
+		result.GeneratedPassword = password
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	name := admin.Name
+	if name == "" {
+		name = "Administrator"
+	}
+	user = models.User{
+		Email:         admin.Email,
+		Password:      string(hashed),
+		Name:          name,
+		EmailVerified: true,
+		Roles:         roles,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return err
+	}
+	result.AdminCreated = true
+	return nil
+}
+
+// GeneratePassword returns a random password with 144 bits of entropy
+func GeneratePassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
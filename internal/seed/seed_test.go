@@ -0,0 +1,37 @@
+package seed
+
+import "testing"
+
+func TestAdminValidate(t *testing.T) {
+	valid := []Admin{
+		{},
+		{Email: "admin@example.com"},
+		{Email: "admin@example.com", Password: "longenough"},
+	}
+	for _, a := range valid {
+		if err := a.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", a, err)
+		}
+	}
+
+	invalid := []Admin{
+		{Email: "admin"},
+		{Email: "admin@example.com", Password: "short"},
+	}
+	for _, a := range invalid {
+		if err := a.Validate(); err == nil {
+			t.Errorf("Validate(%+v): expected an error", a)
+		}
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	a, err := GeneratePassword()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := GeneratePassword()
+	if len(a) != 24 || a == b {
+		t.Errorf("GeneratePassword() = %q, %q; want distinct 24-character passwords", a, b)
+	}
+}
@@ -1,12 +1,20 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"time"
 
+	"github.com/ristep/smanzy_backend/internal/jobs"
 	"github.com/ristep/smanzy_backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// JobPurgeAlbums is the job type of the periodic purge of expired album trash
+const JobPurgeAlbums = "album.purge_trash"
+
 // AlbumService handles business logic for album operations
 type AlbumService struct {
 	db *gorm.DB
@@ -17,6 +25,11 @@ func NewAlbumService(db *gorm.DB) *AlbumService {
 	return &AlbumService{db: db}
 }
 
+// WithContext returns a copy of the service running its queries with ctx
+func (as *AlbumService) WithContext(ctx context.Context) *AlbumService {
+	return &AlbumService{db: as.db.WithContext(ctx)}
+}
+
 // CreateAlbum creates a new album for a user
 func (as *AlbumService) CreateAlbum(userID uint, title, description string) (*models.Album, error) {
 	if title == "" {
@@ -152,9 +165,60 @@ func (as *AlbumService) DeleteAlbum(albumID uint) error {
 	return nil
 }
 
-// PermanentlyDeleteAlbum permanently deletes an album from the database
+// GetDeletedAlbumByID retrieves a soft-deleted album by its ID
+func (as *AlbumService) GetDeletedAlbumByID(albumID uint) (*models.Album, error) {
+	var album models.Album
+	if err := as.db.Unscoped().Where("deleted_at IS NOT NULL").First(&album, albumID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("album not found in trash")
+		}
+		return nil, err
+	}
+	return &album, nil
+}
+
+// GetDeletedUserAlbums retrieves the soft-deleted albums of a user, most
+// recently deleted first
+func (as *AlbumService) GetDeletedUserAlbums(userID uint) ([]models.Album, error) {
+	var albums []models.Album
+	if err := as.db.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at desc").
+		Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+// RestoreAlbum undoes the soft delete of an album
+func (as *AlbumService) RestoreAlbum(albumID uint) (*models.Album, error) {
+	album, err := as.GetDeletedAlbumByID(albumID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := as.db.Unscoped().Model(album).Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+
+	return as.GetAlbumByID(albumID)
+}
+
+// GetAlbumByIDUnscoped retrieves an album by its ID, including albums in the trash
+func (as *AlbumService) GetAlbumByIDUnscoped(albumID uint) (*models.Album, error) {
+	var album models.Album
+	if err := as.db.Unscoped().First(&album, albumID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("album not found")
+		}
+		return nil, err
+	}
+	return &album, nil
+}
+
+// PermanentlyDeleteAlbum permanently deletes an album from the database,
+// whether or not it is in the trash. The media in it are not deleted.
 func (as *AlbumService) PermanentlyDeleteAlbum(albumID uint) error {
-	album, err := as.GetAlbumByID(albumID)
+	album, err := as.GetAlbumByIDUnscoped(albumID)
 	if err != nil {
 		return err
 	}
@@ -171,3 +235,54 @@ func (as *AlbumService) PermanentlyDeleteAlbum(albumID uint) error {
 
 	return nil
 }
+
+// PurgeDeletedAlbums permanently deletes albums that were moved to the
+// trash before the cutoff and returns how many were deleted
+func (as *AlbumService) PurgeDeletedAlbums(ctx context.Context, cutoff time.Time) (int, error) {
+	var ids []uint
+	if err := as.db.WithContext(ctx).Unscoped().Model(&models.Album{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		err := as.db.Transaction(func(tx *gorm.DB) error {
+			// Skip albums restored since they were listed
+			var album models.Album
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+				First(&album, id).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&album).Association("MediaFiles").Clear(); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&album).Error
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Register adds the handler purging albums kept in the trash longer than
+// retention to a worker
+func (as *AlbumService) Register(w *jobs.Worker, retention time.Duration) {
+	w.Handle(JobPurgeAlbums, func(ctx context.Context, job *models.Job) error {
+		purged, err := as.PurgeDeletedAlbums(ctx, time.Now().Add(-retention))
+		if purged > 0 {
+			slog.InfoContext(ctx, "Purged albums from the trash", "count", purged)
+		}
+		return err
+	})
+}
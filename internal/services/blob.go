@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBlobFileRemoved is returned by Acquire when the stored object was
+// removed as unreferenced between being written and being acquired. The
+// transaction stays usable, so the caller can store the file again and
+// retry while the lock taken by Acquire keeps it in place.
+var ErrBlobFileRemoved = errors.New("stored file was removed concurrently")
+
+// BlobService maintains reference counts for content-addressed files
+type BlobService struct {
+	db    *gorm.DB
+	store *storage.Local
+}
+
+// NewBlobService creates a new blob service
+func NewBlobService(db *gorm.DB, store *storage.Local) *BlobService {
+	return &BlobService{db: db, store: store}
+}
+
+// WithContext returns a copy of the service running its queries and storage
+// operations with ctx
+func (bs *BlobService) WithContext(ctx context.Context) *BlobService {
+	return &BlobService{db: bs.db.WithContext(ctx), store: bs.store.WithContext(ctx)}
+}
+
+// Acquire records a new reference to a stored object and returns the
+// canonical blob for its content. Must be called inside tx together with
+// the write of the referencing record.
+//
+// If the content was already stored under a different name (e.g. another
+// extension), the returned blob's StoredName differs from obj.Key and the
+// caller should use the blob's name and Discard the duplicate file.
+func (bs *BlobService) Acquire(tx *gorm.DB, obj *storage.Object) (*models.Blob, error) {
+	// A concurrent RemoveFile may have deleted the file since it was
+	// written; once locked, it waits for this transaction and sees the blob
+	if err := lockFile(tx, obj.Key); err != nil {
+		return nil, err
+	}
+	if _, err := bs.store.Stat(obj.Key); os.IsNotExist(err) {
+		return nil, ErrBlobFileRemoved
+	} else if err != nil {
+		return nil, err
+	}
+
+	blob := models.Blob{
+		Hash:       obj.Hash,
+		StoredName: obj.Key,
+		Size:       obj.Size,
+		RefCount:   1,
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("blobs.ref_count + 1")}),
+	}).Create(&blob).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.First(&blob, "hash = ?", obj.Hash).Error; err != nil {
+		return nil, err
+	}
+
+	return &blob, nil
+}
+
+// Release drops a reference to the blob with the given hash inside tx.
+// It returns the stored name of the file when this was the last reference;
+// the caller must RemoveFile it once the transaction has committed.
+func (bs *BlobService) Release(tx *gorm.DB, hash string) (string, error) {
+	var blob models.Blob
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&blob, "hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if blob.RefCount > 1 {
+		return "", tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+	}
+
+	if err := tx.Delete(&blob).Error; err != nil {
+		return "", err
+	}
+	return blob.StoredName, nil
+}
+
+// RemoveFile deletes a file whose blob was released, unless the content
+// was uploaded again in the meantime. The check and the removal hold the
+// same lock as Acquire.
+func (bs *BlobService) RemoveFile(storedName string) error {
+	return bs.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockFile(tx, storedName); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Blob{}).Where("stored_name = ?", storedName).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		return bs.store.Remove(storedName)
+	})
+}
+
+// lockFile takes a transaction-scoped lock on a stored name, serializing
+// new references to a file with its removal
+func lockFile(tx *gorm.DB, storedName string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", storedName).Error
+}
+
+// Discard removes a freshly stored object that ended up unreferenced,
+// either because the database write failed or because the same content
+// already exists under another name
+func (bs *BlobService) Discard(obj *storage.Object) error {
+	return bs.RemoveFile(obj.Key)
+}
+
+// ReleaseRenditions deletes renditions of a media record inside tx and
+// releases their blobs. When kinds are given, only renditions of those
+// kinds are removed. It returns the stored names of files that are no
+// longer referenced; the caller must RemoveFile them after commit.
+func (bs *BlobService) ReleaseRenditions(tx *gorm.DB, mediaID uint, kinds ...string) ([]string, error) {
+	query := tx.Where("media_id = ?", mediaID)
+	if len(kinds) > 0 {
+		query = query.Where("kind IN ?", kinds)
+	}
+
+	var renditions []models.Rendition
+	if err := query.Find(&renditions).Error; err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for _, r := range renditions {
+		if err := tx.Delete(&r).Error; err != nil {
+			return nil, err
+		}
+		if r.ContentHash == "" {
+			continue
+		}
+		orphan, err := bs.Release(tx, r.ContentHash)
+		if err != nil {
+			return nil, err
+		}
+		if orphan != "" {
+			orphans = append(orphans, orphan)
+		}
+	}
+	return orphans, nil
+}
+
+// ReleaseVersions deletes all but the newest keep versions of a media
+// record inside tx and releases their files. It returns the stored names
+// of files that are no longer referenced; the caller must RemoveFile them
+// after commit.
+func (bs *BlobService) ReleaseVersions(tx *gorm.DB, mediaID uint, keep int) ([]string, error) {
+	var versions []models.MediaVersion
+	if err := tx.Where("media_id = ?", mediaID).Order("version desc").Offset(keep).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for _, v := range versions {
+		if err := tx.Delete(&v).Error; err != nil {
+			return nil, err
+		}
+		// Files stored before deduplication belong to this version alone
+		if v.ContentHash == "" {
+			orphans = append(orphans, v.StoredName)
+			continue
+		}
+		orphan, err := bs.Release(tx, v.ContentHash)
+		if err != nil {
+			return nil, err
+		}
+		if orphan != "" {
+			orphans = append(orphans, orphan)
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// MaxMediaPageSize caps the number of media returned per page
+const MaxMediaPageSize = 100
+
+// ErrInvalidCursor is returned for cursors that are malformed or belong to
+// a listing with a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MediaSortFields maps the accepted sort fields to their columns
+var MediaSortFields = map[string]string{
+	"created_at": "media.created_at",
+	"size":       "media.size",
+	"filename":   "media.filename",
+}
+
+// MediaFilter narrows a media listing. Zero values do not filter.
+type MediaFilter struct {
+	Type          string // General category ("image", "video", ...)
+	MimePrefix    string // e.g. "image/" or "video/mp4"
+	UserID        uint
+	CreatedAfter  int64 // Milliseconds since epoch, inclusive
+	CreatedBefore int64 // Milliseconds since epoch, exclusive
+	MinSize       int64 // Bytes, inclusive
+	MaxSize       int64 // Bytes, inclusive
+	Tags          []string
+	MatchAllTags  bool
+}
+
+// MediaListOptions describes a page of a media listing
+type MediaListOptions struct {
+	Filter    MediaFilter
+	SortField string // Key of MediaSortFields, default "created_at"
+	SortAsc   bool   // Ascending order; newest/largest first by default
+	Limit     int    // Page size, capped at MaxMediaPageSize
+	Cursor    string // Opaque cursor from a previous page; takes precedence over Offset
+	Offset    int    // Legacy offset pagination
+	WithTotal bool   // Count all matching media (costly on large tables)
+	Columns   string // Columns to select, all when empty
+}
+
+// MediaList is a page of media
+type MediaList struct {
+	Files      []models.Media `json:"files"`
+	NextCursor string         `json:"next_cursor,omitempty"` // Empty on the last page
+	Total      *int64         `json:"total,omitempty"`
+}
+
+// cursor is the position after the last row of a page: the sort value and
+// ID of that row, plus the sort order it is valid for
+type cursor struct {
+	Field string          `json:"f"`
+	Asc   bool            `json:"a,omitempty"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// encodeCursor returns the opaque form of c
+func encodeCursor(c cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses an opaque cursor
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Value) == 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// sortValue returns the value of the sort field of m
+func sortValue(m *models.Media, field string) interface{} {
+	switch field {
+	case "size":
+		return m.Size
+	case "filename":
+		return m.Filename
+	default:
+		return m.CreatedAt
+	}
+}
+
+// cursorValue decodes the sort value stored in a cursor
+func cursorValue(c cursor) (interface{}, error) {
+	if c.Field == "filename" {
+		var s string
+		err := json.Unmarshal(c.Value, &s)
+		return s, err
+	}
+	var n int64
+	err := json.Unmarshal(c.Value, &n)
+	return n, err
+}
+
+// MediaListService lists media with filters, sorting and keyset pagination
+type MediaListService struct {
+	db *gorm.DB
+}
+
+// NewMediaListService creates a new media list service
+func NewMediaListService(db *gorm.DB) *MediaListService {
+	return &MediaListService{db: db}
+}
+
+// WithContext returns a copy of the service running its queries with ctx
+func (ls *MediaListService) WithContext(ctx context.Context) *MediaListService {
+	return &MediaListService{db: ls.db.WithContext(ctx)}
+}
+
+// filtered applies the filter to a media query
+func filtered(db *gorm.DB, f MediaFilter) *gorm.DB {
+	if f.Type != "" {
+		db = db.Where("media.type = ?", f.Type)
+	}
+	if f.MimePrefix != "" {
+		db = db.Where("media.mime_type LIKE ? ESCAPE '\\'", escapeLike(f.MimePrefix)+"%")
+	}
+	if f.UserID != 0 {
+		db = db.Where("media.user_id = ?", f.UserID)
+	}
+	if f.CreatedAfter != 0 {
+		db = db.Where("media.created_at >= ?", f.CreatedAfter)
+	}
+	if f.CreatedBefore != 0 {
+		db = db.Where("media.created_at < ?", f.CreatedBefore)
+	}
+	if f.MinSize != 0 {
+		db = db.Where("media.size >= ?", f.MinSize)
+	}
+	if f.MaxSize != 0 {
+		db = db.Where("media.size <= ?", f.MaxSize)
+	}
+	return db.Scopes(WithTags(f.Tags, f.MatchAllTags))
+}
+
+// List returns a page of media matching opts
+func (ls *MediaListService) List(opts MediaListOptions) (*MediaList, error) {
+	if opts.SortField == "" {
+		opts.SortField = "created_at"
+	}
+	column, ok := MediaSortFields[opts.SortField]
+	if !ok {
+		return nil, errors.New("invalid sort field")
+	}
+	if opts.Limit <= 0 || opts.Limit > MaxMediaPageSize {
+		opts.Limit = MaxMediaPageSize
+	}
+
+	list := &MediaList{Files: []models.Media{}}
+
+	if opts.WithTotal {
+		var total int64
+		if err := filtered(ls.db.Model(&models.Media{}), opts.Filter).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		list.Total = &total
+	}
+
+	dir := "DESC"
+	cmp := "<"
+	if opts.SortAsc {
+		dir, cmp = "ASC", ">"
+	}
+
+	query := filtered(ls.db.Model(&models.Media{}), opts.Filter)
+	if opts.Columns != "" {
+		query = query.Select(opts.Columns)
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil || c.Field != opts.SortField || c.Asc != opts.SortAsc {
+			return nil, ErrInvalidCursor
+		}
+		value, err := cursorValue(c)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query = query.Where("("+column+", media.id) "+cmp+" (?, ?)", value, c.ID)
+	} else if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	if err := query.Preload("Tags").
+		Order(column + " " + dir).Order("media.id " + dir).
+		Limit(opts.Limit + 1).
+		Find(&list.Files).Error; err != nil {
+		return nil, err
+	}
+
+	if len(list.Files) > opts.Limit {
+		list.Files = list.Files[:opts.Limit]
+		last := &list.Files[len(list.Files)-1]
+		value, err := json.Marshal(sortValue(last, opts.SortField))
+		if err != nil {
+			return nil, err
+		}
+		if list.NextCursor, err = encodeCursor(cursor{Field: opts.SortField, Asc: opts.SortAsc, Value: value, ID: last.ID}); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	value, _ := json.Marshal("IMG_0001.jpg")
+	encoded, err := encodeCursor(cursor{Field: "filename", Asc: true, Value: value, ID: 42})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	c, err := decodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if c.Field != "filename" || !c.Asc || c.ID != 42 {
+		t.Fatalf("unexpected cursor %+v", c)
+	}
+	if v, err := cursorValue(c); err != nil || v != "IMG_0001.jpg" {
+		t.Fatalf("cursorValue() = %v, %v", v, err)
+	}
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "e30"} { // "e30" is "{}"
+		if _, err := decodeCursor(s); err != ErrInvalidCursor {
+			t.Errorf("decodeCursor(%q) error = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagCount is a tag with the number of media it is attached to
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// TagService manages tags on media
+type TagService struct {
+	db *gorm.DB
+}
+
+// NewTagService creates a new tag service
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{db: db}
+}
+
+// WithContext returns a copy of the service running its queries with ctx
+func (ts *TagService) WithContext(ctx context.Context) *TagService {
+	return &TagService{db: ts.db.WithContext(ctx)}
+}
+
+// NormalizeTags normalizes tag names and removes duplicates
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		tag, err := models.NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return out, nil
+}
+
+// AddTags attaches tags to a media record, creating tags that do not exist
+// yet, and returns all tags of the media
+func (ts *TagService) AddTags(mediaID uint, names []string) ([]models.Tag, error) {
+	names, err := NormalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ts.db.Transaction(func(tx *gorm.DB) error {
+		if len(names) == 0 {
+			return nil
+		}
+
+		tags := make([]models.Tag, len(names))
+		for i, name := range names {
+			tags[i] = models.Tag{Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+			return err
+		}
+
+		var ids []uint
+		if err := tx.Model(&models.Tag{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := tx.Exec("INSERT INTO media_tags (media_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING", mediaID, id).Error; err != nil {
+				return err
+			}
+		}
+		return refreshTagNames(tx, mediaID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ts.MediaTags(mediaID)
+}
+
+// RemoveTag detaches a tag from a media record and returns the remaining tags
+func (ts *TagService) RemoveTag(mediaID uint, name string) ([]models.Tag, error) {
+	name, err := models.NormalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM media_tags WHERE media_id = ? AND tag_id IN (SELECT id FROM tags WHERE name = ?)`,
+			mediaID, name).Error; err != nil {
+			return err
+		}
+		return refreshTagNames(tx, mediaID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ts.MediaTags(mediaID)
+}
+
+// MediaTags returns the tags of a media record ordered by name
+func (ts *TagService) MediaTags(mediaID uint) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := ts.db.Joins("JOIN media_tags ON media_tags.tag_id = tags.id").
+		Where("media_tags.media_id = ?", mediaID).
+		Order("tags.name").
+		Find(&tags).Error
+	return tags, err
+}
+
+// UserTags returns the tags used on a user's media with their counts,
+// most used first
+func (ts *TagService) UserTags(userID uint) ([]TagCount, error) {
+	counts := []TagCount{}
+	err := ts.db.Table("tags").
+		Select("tags.name, count(*) AS count").
+		Joins("JOIN media_tags ON media_tags.tag_id = tags.id").
+		Joins("JOIN media ON media.id = media_tags.media_id AND media.deleted_at IS NULL").
+		Where("media.user_id = ?", userID).
+		Group("tags.name").
+		Order("count DESC, tags.name").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// Autocomplete returns tags in use that start with prefix, most used first
+func (ts *TagService) Autocomplete(prefix string, limit int) ([]TagCount, error) {
+	counts := []TagCount{}
+	prefix = strings.ToLower(strings.Join(strings.Fields(prefix), " "))
+	if prefix == "" {
+		return counts, nil
+	}
+
+	err := ts.db.Table("tags").
+		Select("tags.name, count(*) AS count").
+		Joins("JOIN media_tags ON media_tags.tag_id = tags.id").
+		Joins("JOIN media ON media.id = media_tags.media_id AND media.deleted_at IS NULL").
+		Where("tags.name LIKE ? ESCAPE '\\'", escapeLike(prefix)+"%").
+		Group("tags.name").
+		Order("count DESC, tags.name").
+		Limit(limit).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// WithTags returns a scope limiting a media query to media tagged with all
+// (matchAll) or any of the given normalized tag names
+func WithTags(names []string, matchAll bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(names) == 0 {
+			return db
+		}
+		sub := db.Session(&gorm.Session{NewDB: true}).Table("media_tags").
+			Select("media_tags.media_id").
+			Joins("JOIN tags ON tags.id = media_tags.tag_id").
+			Where("tags.name IN ?", names)
+		if matchAll {
+			sub = sub.Group("media_tags.media_id").Having("count(DISTINCT tags.id) = ?", len(names))
+		}
+		return db.Where("media.id IN (?)", sub)
+	}
+}
+
+// refreshTagNames updates the tag names mirrored on the media record for
+// full-text search
+func refreshTagNames(tx *gorm.DB, mediaID uint) error {
+	return tx.Exec(`UPDATE media SET tag_names = (
+			SELECT string_agg(tags.name, ' ' ORDER BY tags.name)
+			FROM media_tags JOIN tags ON tags.id = media_tags.tag_id
+			WHERE media_tags.media_id = ?
+		) WHERE id = ?`, mediaID, mediaID).Error
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags([]string{" Beach ", "summer   2024", "beach", "SUMMER 2024"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"beach", "summer 2024"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags() = %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"", "   ", "a,b", strings.Repeat("x", models.MaxTagLength+1)} {
+		if _, err := NormalizeTags([]string{name}); err != models.ErrInvalidTag {
+			t.Errorf("NormalizeTags(%q) error = %v, want ErrInvalidTag", name, err)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("escapeLike() = %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/jobs"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobPurgeTrash is the job type of the periodic purge of expired trash
+const JobPurgeTrash = "media.purge_trash"
+
+// TrashService permanently removes soft-deleted media and their files
+type TrashService struct {
+	db    *gorm.DB
+	store *storage.Local
+	blobs *BlobService
+}
+
+// NewTrashService creates a new trash service
+func NewTrashService(db *gorm.DB, store *storage.Local) *TrashService {
+	return &TrashService{db: db, store: store, blobs: NewBlobService(db, store)}
+}
+
+// WithContext returns a copy of the service running its queries and storage
+// operations with ctx
+func (ts *TrashService) WithContext(ctx context.Context) *TrashService {
+	return NewTrashService(ts.db.WithContext(ctx), ts.store.WithContext(ctx))
+}
+
+// Register adds the handler purging media kept in the trash longer than
+// retention to a worker
+func (ts *TrashService) Register(w *jobs.Worker, retention time.Duration) {
+	w.Handle(JobPurgeTrash, func(ctx context.Context, job *models.Job) error {
+		purged, err := ts.PurgeExpired(ctx, time.Now().Add(-retention))
+		if purged > 0 {
+			slog.InfoContext(ctx, "Purged media from the trash", "count", purged)
+		}
+		return err
+	})
+}
+
+// Purge hard-deletes a media record in the trash together with its
+// renditions, versions, album memberships, tags and files.
+// Returns gorm.ErrRecordNotFound if the media is not in the trash.
+func (ts *TrashService) Purge(mediaID uint) error {
+	return ts.purge(mediaID, time.Time{})
+}
+
+// purge hard-deletes a media record moved to the trash before the cutoff,
+// or at any time if the cutoff is zero
+func (ts *TrashService) purge(mediaID uint, cutoff time.Time) error {
+	var media models.Media
+	var orphans []string
+
+	err := ts.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the record so a concurrent restore either finishes first and
+		// is seen here, or waits until the record is gone
+		query := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("deleted_at IS NOT NULL")
+		if !cutoff.IsZero() {
+			query = query.Where("deleted_at < ?", cutoff)
+		}
+		if err := query.First(&media, mediaID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM album_media WHERE media_id = ?", media.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM media_tags WHERE media_id = ?", media.ID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if orphans, err = ts.blobs.ReleaseRenditions(tx, media.ID); err != nil {
+			return err
+		}
+		versions, err := ts.blobs.ReleaseVersions(tx, media.ID, 0)
+		if err != nil {
+			return err
+		}
+		orphans = append(orphans, versions...)
+
+		// Files uploaded before deduplication are owned by a single record
+		if media.ContentHash == "" {
+			orphans = append(orphans, media.StoredName)
+		} else {
+			orphan, err := ts.blobs.Release(tx, media.ContentHash)
+			if err != nil {
+				return err
+			}
+			orphans = append(orphans, orphan)
+		}
+
+		return tx.Unscoped().Delete(&media).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// The record is gone, so failures are logged and the files are left
+	// for cmd/reconcile
+	ctx := ts.db.Statement.Context
+	for _, name := range orphans {
+		if name == "" {
+			continue
+		}
+		if err := ts.blobs.RemoveFile(name); err != nil {
+			slog.WarnContext(ctx, "Failed to delete file", "media_id", media.ID, "file", name, "error", err)
+		}
+	}
+	if err := ts.store.RemoveAll(models.HLSPrefix(media.ID)); err != nil {
+		slog.WarnContext(ctx, "Failed to delete HLS files", "media_id", media.ID, "error", err)
+	}
+	return nil
+}
+
+// PurgeExpired purges media that were moved to the trash before the cutoff
+// and returns how many were purged
+func (ts *TrashService) PurgeExpired(ctx context.Context, cutoff time.Time) (int, error) {
+	var ids []uint
+	if err := ts.db.WithContext(ctx).Unscoped().Model(&models.Media{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	return ts.purgeIDs(ctx, ids, cutoff)
+}
+
+// purgeIDs purges the given media one by one and returns how many were
+// purged. Media restored, trashed again after the cutoff or purged
+// meanwhile are skipped.
+func (ts *TrashService) purgeIDs(ctx context.Context, ids []uint, cutoff time.Time) (int, error) {
+	purged := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		if err := ts.purge(id, cutoff); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// TrashUserMedia moves all media of a user to the trash and returns how many
+// were moved
+func (ts *TrashService) TrashUserMedia(userID uint) (int64, error) {
+	res := ts.db.Where("user_id = ?", userID).Delete(&models.Media{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeUserMedia purges the media of a user that are in the trash and
+// returns how many were purged
+func (ts *TrashService) PurgeUserMedia(ctx context.Context, userID uint) (int, error) {
+	var ids []uint
+	if err := ts.db.WithContext(ctx).Unscoped().Model(&models.Media{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	return ts.purgeIDs(ctx, ids, time.Time{})
+}
+
+// FileTrashed reports whether a stored file belongs only to media in the
+// trash, as their original, rendition or version. Files no media refers
+// to are not considered trashed.
+func (ts *TrashService) FileTrashed(storedName string) (bool, error) {
+	refs := func() *gorm.DB {
+		return ts.db.Unscoped().Model(&models.Media{}).Where(
+			"stored_name = @name OR id IN (SELECT media_id FROM renditions WHERE stored_name = @name) OR id IN (SELECT media_id FROM media_versions WHERE stored_name = @name)",
+			sql.Named("name", storedName))
+	}
+
+	var live int64
+	if err := refs().Where("deleted_at IS NULL").Count(&live).Error; err != nil || live > 0 {
+		return false, err
+	}
+	var total int64
+	if err := refs().Count(&total).Error; err != nil {
+		return false, err
+	}
+	return total > 0, nil
+}
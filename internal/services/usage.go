@@ -0,0 +1,53 @@
+package services
+
+import "gorm.io/gorm"
+
+// MediaUsage is the storage used by the media of a user. Sizes are the sum
+// of the file sizes; files shared through deduplication are counted for
+// every record, so the space taken on disk can be smaller.
+type MediaUsage struct {
+	UserID       uint   `json:"user_id"`
+	Email        string `json:"email"`
+	Files        int64  `json:"files"`
+	Bytes        int64  `json:"bytes"`
+	TrashedFiles int64  `json:"trashed_files"`
+	TrashedBytes int64  `json:"trashed_bytes"`
+	Versions     int64  `json:"versions"`
+	VersionBytes int64  `json:"version_bytes"` // Previous files kept for restoring
+}
+
+// UsageService reports storage usage
+type UsageService struct {
+	db *gorm.DB
+}
+
+// NewUsageService creates a new usage service
+func NewUsageService(db *gorm.DB) *UsageService {
+	return &UsageService{db: db}
+}
+
+// MediaUsage returns the usage of every user with media, largest first,
+// or of a single user when userID is not zero
+func (us *UsageService) MediaUsage(userID uint) ([]MediaUsage, error) {
+	query := us.db.Table("users").
+		Select(`users.id AS user_id, users.email,
+			count(media.id) FILTER (WHERE media.deleted_at IS NULL) AS files,
+			coalesce(sum(media.size) FILTER (WHERE media.deleted_at IS NULL), 0) AS bytes,
+			count(media.id) FILTER (WHERE media.deleted_at IS NOT NULL) AS trashed_files,
+			coalesce(sum(media.size) FILTER (WHERE media.deleted_at IS NOT NULL), 0) AS trashed_bytes,
+			coalesce(sum(v.count), 0) AS versions,
+			coalesce(sum(v.size), 0) AS version_bytes`).
+		Joins("JOIN media ON media.user_id = users.id").
+		Joins(`CROSS JOIN LATERAL (
+			SELECT count(*) AS count, coalesce(sum(size), 0) AS size FROM media_versions WHERE media_versions.media_id = media.id
+		) v`).
+		Group("users.id, users.email").
+		Order("bytes DESC, users.id")
+	if userID != 0 {
+		query = query.Where("users.id = ?", userID)
+	}
+
+	usage := []MediaUsage{}
+	err := query.Scan(&usage).Error
+	return usage, err
+}
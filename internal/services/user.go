@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// User management errors
+var (
+	ErrUserExists    = errors.New("user already exists")
+	ErrRoleNotFound  = errors.New("role not found")
+	ErrWeakPassword  = fmt.Errorf("password must be at least %d characters", models.MinPasswordLength)
+	ErrInvalidUserID = errors.New("invalid user ID or email")
+)
+
+// UserService manages user accounts
+type UserService struct {
+	db *gorm.DB
+}
+
+// NewUserService creates a new user service
+func NewUserService(db *gorm.DB) *UserService {
+	return &UserService{db: db}
+}
+
+// Find returns the user with the given ID or email, with roles. Deleted
+// users are included so that their data can still be managed.
+func (us *UserService) Find(idOrEmail string) (*models.User, error) {
+	if idOrEmail == "" {
+		return nil, ErrInvalidUserID
+	}
+	query := us.db.Unscoped().Preload("Roles")
+	if id, err := strconv.ParseUint(idOrEmail, 10, 32); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("email = ?", idOrEmail)
+	}
+
+	var user models.User
+	if err := query.First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create creates a user with the given roles
+func (us *UserService) Create(email, password, name string, roles ...string) (*models.User, error) {
+	if len(password) < models.MinPasswordLength {
+		return nil, ErrWeakPassword
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Email: email, Password: string(hashed), Name: name}
+	err = us.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+
+		if len(roles) > 0 {
+			if err := tx.Where("name IN ?", roles).Find(&user.Roles).Error; err != nil {
+				return err
+			}
+			if len(user.Roles) != len(roles) {
+				return ErrRoleNotFound
+			}
+		}
+		return tx.Create(user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetPassword replaces the password of a user
+func (us *UserService) SetPassword(userID uint, password string) error {
+	if len(password) < models.MinPasswordLength {
+		return ErrWeakPassword
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return us.update(userID, "password", string(hashed))
+}
+
+// SetDisabled disables or re-enables a user account
+func (us *UserService) SetDisabled(userID uint, disabled bool) error {
+	return us.update(userID, "disabled", disabled)
+}
+
+// AssignRole gives a user a role
+func (us *UserService) AssignRole(userID uint, roleName string) error {
+	return us.changeRole(userID, roleName, true)
+}
+
+// RemoveRole takes a role from a user
+func (us *UserService) RemoveRole(userID uint, roleName string) error {
+	return us.changeRole(userID, roleName, false)
+}
+
+// changeRole adds or removes a role of a user, deleted or not
+func (us *UserService) changeRole(userID uint, roleName string, add bool) error {
+	var role models.Role
+	if err := us.db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+
+	var user models.User
+	if err := us.db.Unscoped().First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	association := us.db.Unscoped().Model(&user).Association("Roles")
+	if add {
+		return association.Append(&role)
+	}
+	return association.Delete(&role)
+}
+
+// update sets a column of a user. Like Find, it includes deleted users.
+func (us *UserService) update(userID uint, column string, value interface{}) error {
+	res := us.db.Unscoped().Model(&models.User{}).Where("id = ?", userID).Update(column, value)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
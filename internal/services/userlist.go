@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// MaxUserPageSize caps the number of users returned per page
+const MaxUserPageSize = 100
+
+// UserSortFields maps the accepted sort fields to their columns
+var UserSortFields = map[string]string{
+	"created_at": "users.created_at",
+	"email":      "users.email",
+	"name":       "users.name",
+	"id":         "users.id",
+}
+
+// UserFilter narrows a user listing. Zero values do not filter.
+type UserFilter struct {
+	Search         string // Case-insensitive substring of the email or name
+	Role           string
+	Verified       *bool
+	Disabled       *bool
+	Country        string // Case-insensitive exact match
+	CreatedAfter   int64  // Milliseconds since epoch, inclusive
+	CreatedBefore  int64  // Milliseconds since epoch, exclusive
+	IncludeDeleted bool
+}
+
+// UserListOptions describes a page of a user listing
+type UserListOptions struct {
+	Filter    UserFilter
+	SortField string // Key of UserSortFields, default "created_at"
+	SortAsc   bool   // Ascending order; newest first by default
+	Limit     int    // Page size, capped at MaxUserPageSize
+	Offset    int
+}
+
+// ListedUser is a user in a listing. DeletedAt is set for soft-deleted users.
+type ListedUser struct {
+	models.User
+	DeletedAt *int64 `json:"deleted_at,omitempty"` // Milliseconds since epoch
+}
+
+// UserList is a page of users
+type UserList struct {
+	Users []ListedUser `json:"users"`
+	Total int64        `json:"total"`
+}
+
+// UserListService lists users for administration
+type UserListService struct {
+	db *gorm.DB
+}
+
+// NewUserListService creates a new user list service
+func NewUserListService(db *gorm.DB) *UserListService {
+	return &UserListService{db: db}
+}
+
+// WithContext returns a copy of the service running its queries with ctx
+func (us *UserListService) WithContext(ctx context.Context) *UserListService {
+	return &UserListService{db: us.db.WithContext(ctx)}
+}
+
+// filteredUsers applies the filter to a user query
+func filteredUsers(db *gorm.DB, f UserFilter) *gorm.DB {
+	if f.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	if s := strings.TrimSpace(f.Search); s != "" {
+		pattern := "%" + escapeLike(s) + "%"
+		db = db.Where("(users.email ILIKE ? ESCAPE '\\' OR users.name ILIKE ? ESCAPE '\\')", pattern, pattern)
+	}
+	if f.Role != "" {
+		db = db.Where("users.id IN (SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = ?)", f.Role)
+	}
+	if f.Verified != nil {
+		db = db.Where("users.email_verified = ?", *f.Verified)
+	}
+	if f.Disabled != nil {
+		db = db.Where("users.disabled = ?", *f.Disabled)
+	}
+	if f.Country != "" {
+		db = db.Where("lower(users.country) = lower(?)", f.Country)
+	}
+	if f.CreatedAfter != 0 {
+		db = db.Where("users.created_at >= ?", f.CreatedAfter)
+	}
+	if f.CreatedBefore != 0 {
+		db = db.Where("users.created_at < ?", f.CreatedBefore)
+	}
+	return db
+}
+
+// List returns a page of users matching opts together with their roles and
+// the number of all matching users
+func (us *UserListService) List(opts UserListOptions) (*UserList, error) {
+	if opts.SortField == "" {
+		opts.SortField = "created_at"
+	}
+	column, ok := UserSortFields[opts.SortField]
+	if !ok {
+		return nil, errors.New("invalid sort field")
+	}
+	if opts.Limit <= 0 || opts.Limit > MaxUserPageSize {
+		opts.Limit = MaxUserPageSize
+	}
+
+	list := &UserList{Users: []ListedUser{}}
+	if err := filteredUsers(us.db.Model(&models.User{}), opts.Filter).Count(&list.Total).Error; err != nil {
+		return nil, err
+	}
+	if list.Total == 0 {
+		return list, nil
+	}
+
+	dir := "DESC"
+	if opts.SortAsc {
+		dir = "ASC"
+	}
+
+	var users []models.User
+	if err := filteredUsers(us.db, opts.Filter).Preload("Roles").
+		Order(column + " " + dir).Order("users.id " + dir).
+		Limit(opts.Limit).Offset(opts.Offset).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	for _, u := range users {
+		item := ListedUser{User: u}
+		if u.DeletedAt.Valid {
+			ms := u.DeletedAt.Time.UnixMilli()
+			item.DeletedAt = &ms
+		}
+		list.Users = append(list.Users, item)
+	}
+	return list, nil
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidKey is returned when a key would escape the storage root
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// ErrFreeSpaceUnsupported is returned by FreeSpace on platforms where the
+// free space cannot be determined
+var ErrFreeSpaceUnsupported = errors.New("free space is not supported on this platform")
+
+// Object describes a file written to the store
+type Object struct {
+	Key  string // Name of the file relative to the storage root
+	Hash string // Hex-encoded SHA-256 of the content
+	Size int64  // Size in bytes
+}
+
+// Local stores files in a directory on the local filesystem.
+// Keys are slash-separated paths relative to the root directory.
+type Local struct {
+	root string
+	ctx  context.Context // Parent of operation spans, see WithContext
+}
+
+// NewLocal creates a local store rooted at dir, creating the directory if needed
+func NewLocal(dir string) *Local {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	return &Local{root: dir}
+}
+
+// WithContext returns a store whose operations are traced as children of
+// the span in ctx
+func (l *Local) WithContext(ctx context.Context) *Local {
+	clone := *l
+	clone.ctx = ctx
+	return &clone
+}
+
+// startSpan starts the span of a storage operation on key. Like database
+// statements, operations are only traced as part of a trace; otherwise the
+// span is a no-op.
+func (l *Local) startSpan(operation, key string) trace.Span {
+	if l.ctx == nil || !trace.SpanContextFromContext(l.ctx).IsValid() {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := tracing.Tracer().Start(l.ctx, "storage."+operation)
+	if key != "" {
+		span.SetAttributes(attribute.String("storage.key", key))
+	}
+	return span
+}
+
+// Root returns the directory the store writes to
+func (l *Local) Root() string {
+	return l.root
+}
+
+// Path resolves a key to a path on disk, rejecting keys that would
+// escape the storage root
+func (l *Local) Path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// PutHashed streams r into the store under a content-addressed key
+// (<sha256><ext>). Writing the same content twice yields the same key, so
+// callers can use the returned hash to deduplicate.
+func (l *Local) PutHashed(r io.Reader, ext string) (obj *Object, err error) {
+	span := l.startSpan("put_hashed", "")
+	defer func() {
+		if obj != nil {
+			span.SetAttributes(attribute.String("storage.key", obj.Key), attribute.Int64("storage.size", obj.Size))
+		}
+		tracing.EndSpan(span, err)
+	}()
+
+	tmp, err := os.CreateTemp(l.root, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	key := hash + CleanExt(ext)
+
+	// Renaming over an existing blob is safe: the content is identical
+	if err := os.Rename(tmpName, filepath.Join(l.root, key)); err != nil {
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return &Object{Key: key, Hash: hash, Size: size}, nil
+}
+
+// Put atomically writes r to the file stored under key, replacing any
+// existing file
+func (l *Local) Put(key string, r io.Reader) (size int64, err error) {
+	span := l.startSpan("put", key)
+	defer func() {
+		span.SetAttributes(attribute.Int64("storage.size", size))
+		tracing.EndSpan(span, err)
+	}()
+
+	path, err := l.Path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	size, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return 0, fmt.Errorf("failed to store file: %w", err)
+	}
+	return size, nil
+}
+
+// Open opens the file stored under key for reading
+func (l *Local) Open(key string) (f *os.File, err error) {
+	span := l.startSpan("open", key)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	path, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Stat returns file info for the file stored under key
+func (l *Local) Stat(key string) (info os.FileInfo, err error) {
+	span := l.startSpan("stat", key)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	path, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
+
+// Remove deletes the file stored under key. Missing files are not an error.
+func (l *Local) Remove(key string) (err error) {
+	span := l.startSpan("remove", key)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RemoveAll deletes every file stored under the key prefix (a directory)
+func (l *Local) RemoveAll(prefix string) (err error) {
+	span := l.startSpan("remove_all", prefix)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	path, err := l.Path(prefix)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// Walk calls fn for every file in the store with its key, including
+// temporary files of uploads in progress
+func (l *Local) Walk(fn func(key string, info fs.FileInfo) error) (err error) {
+	span := l.startSpan("walk", "")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	return filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed while walking
+			}
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info)
+	})
+}
+
+// CheckWritable verifies that files can be created in the store by writing
+// and removing a temporary file
+func (l *Local) CheckWritable() error {
+	tmp, err := os.CreateTemp(l.root, ".upload-*")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	_, err = tmp.WriteString("ok")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// IsContentAddressed reports whether key was produced by PutHashed
+func IsContentAddressed(key string) bool {
+	name := strings.TrimSuffix(key, filepath.Ext(key))
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// CleanExt normalizes a file extension taken from a user supplied filename.
+// Anything that is not a short alphanumeric extension is dropped.
+func CleanExt(ext string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if ext == "" || len(ext) > 10 {
+		return ""
+	}
+	for _, r := range ext {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return "." + ext
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPutHashed_DeduplicatesContent(t *testing.T) {
+	store := NewLocal(t.TempDir())
+
+	first, err := store.PutHashed(strings.NewReader("same bytes"), ".JPG")
+	if err != nil {
+		t.Fatalf("first put failed: %v", err)
+	}
+	second, err := store.PutHashed(strings.NewReader("same bytes"), ".jpg")
+	if err != nil {
+		t.Fatalf("second put failed: %v", err)
+	}
+
+	if first.Key != second.Key || first.Hash != second.Hash {
+		t.Fatalf("expected identical content to share a key, got %q and %q", first.Key, second.Key)
+	}
+	if !strings.HasSuffix(first.Key, ".jpg") || !IsContentAddressed(first.Key) {
+		t.Fatalf("unexpected key %q", first.Key)
+	}
+
+	entries, err := os.ReadDir(store.Root())
+	if err != nil {
+		t.Fatalf("failed to read store: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected a single stored file, found %d", len(entries))
+	}
+}
+
+func TestPath_RejectsTraversal(t *testing.T) {
+	store := NewLocal(t.TempDir())
+
+	for _, key := range []string{"", "../secret", "a/../../b", "/etc/passwd", "a//b"} {
+		if _, err := store.Path(key); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
+
+func TestCleanExt(t *testing.T) {
+	cases := map[string]string{
+		".JPG":               ".jpg",
+		"mp4":                ".mp4",
+		".b c":               "",
+		".tar/../x":          "",
+		".":                  "",
+		".verylongextension": "",
+	}
+	for in, want := range cases {
+		if got := CleanExt(in); got != want {
+			t.Errorf("CleanExt(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package storage
+
+// FreeSpace returns the bytes available on the filesystem holding the
+// store; not supported on this platform
+func (l *Local) FreeSpace() (uint64, error) {
+	return 0, ErrFreeSpaceUnsupported
+}
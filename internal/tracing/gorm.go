@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey stores the span of a statement in its settings
+const spanKey = "tracing:span"
+
+// Plugin is a GORM plugin recording a client span for every statement run
+// with a context that is part of a trace (db.WithContext). Statements
+// without one, such as job polling, are not traced. The span carries the
+// SQL with placeholders, never the bound values.
+type Plugin struct{}
+
+// NewPlugin creates the GORM tracing plugin; install it with db.Use
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+// Name implements gorm.Plugin
+func (p *Plugin) Name() string {
+	return "tracing"
+}
+
+// Initialize implements gorm.Plugin
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	)
+}
+
+// before starts the span of a statement
+func before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+		}
+		if db.Statement.Table != "" {
+			attrs = append(attrs, semconv.DBCollectionName(db.Statement.Table))
+		}
+		_, span := Tracer().Start(ctx, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		db.Statement.Settings.Store(spanKey, span)
+	}
+}
+
+// after ends the span of a statement with its SQL and outcome
+func after(db *gorm.DB) {
+	value, ok := db.Statement.Settings.LoadAndDelete(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil // an expected outcome, not a failure
+	}
+	EndSpan(span, err)
+}
@@ -0,0 +1,85 @@
+// Package tracing sets up OpenTelemetry tracing.
+//
+// Incoming requests continue the trace of a W3C traceparent header (see
+// middleware.TracingMiddleware), and database queries (Plugin) and storage
+// operations record child spans when they run with the request context.
+// Spans are exported with OTLP over HTTP.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this application
+const instrumentationName = "github.com/ristep/smanzy_backend"
+
+// Tracer returns the tracer of the global provider. It is looked up on
+// every call so that spans use the provider installed by Setup.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Options configures tracing
+type Options struct {
+	Enabled     bool    // Export spans; only propagation is set up otherwise
+	Endpoint    string  // OTLP/HTTP traces URL; the OTEL_EXPORTER_OTLP_* variables apply when empty
+	ServiceName string  // Service name of the spans
+	SampleRatio float64 // Share of new traces recorded, from 0 to 1
+}
+
+// Setup installs the W3C trace context and baggage propagator and, when
+// tracing is enabled, a provider exporting spans to the OTLP endpoint. The
+// returned function flushes pending spans and must be called on shutdown.
+func Setup(ctx context.Context, cfg Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(sdktrace.NewBatchSpanProcessor(exporter), cfg.ServiceName, cfg.SampleRatio)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider passing spans to processor. A
+// sampleRatio share of new traces is recorded; requests continuing a trace
+// follow the caller's sampling decision. Tests use it with an in-memory
+// exporter.
+func NewProvider(processor sdktrace.SpanProcessor, serviceName string, sampleRatio float64) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+}
+
+// EndSpan records err, if any, on span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
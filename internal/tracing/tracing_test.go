@@ -0,0 +1,203 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/middleware"
+	"github.com/ristep/smanzy_backend/internal/storage"
+	"github.com/ristep/smanzy_backend/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupExporter records spans in memory for the duration of a test
+func setupExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), tracing.Options{}); err != nil {
+		t.Fatalf("failed to set up tracing: %v", err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(sdktrace.NewSimpleSpanProcessor(exporter), "smanzy-test", 1)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+// dryRunDB builds SQL without a database server
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable"),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.Use(tracing.NewPlugin()); err != nil {
+		t.Fatalf("failed to install plugin: %v", err)
+	}
+	return db
+}
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func attr(span *tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingMiddleware_ContinuesTraceWithChildSpans(t *testing.T) {
+	exporter := setupExporter(t)
+	db := dryRunDB(t)
+	store := storage.NewLocal(t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware(), middleware.TracingMiddleware())
+	router.GET("/widgets/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var w widget
+		db.WithContext(ctx).First(&w, c.Param("id"))
+		if _, err := store.WithContext(ctx).Put("widgets/1.txt", strings.NewReader("hello")); err != nil {
+			t.Errorf("put failed: %v", err)
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/widgets/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	server := findSpan(spans, "GET /widgets/:id")
+	if server == nil {
+		t.Fatalf("expected a server span, got %v", spans)
+	}
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the incoming trace to continue, got trace %s", got)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("expected the caller's span as parent, got %s", got)
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("expected a server span, got %v", server.SpanKind)
+	}
+	if got := attr(server, "http.response.status_code").AsInt64(); got != http.StatusNoContent {
+		t.Errorf("expected status 204 on the span, got %d", got)
+	}
+	if got := attr(server, "request.id").AsString(); got != "req-1" {
+		t.Errorf("expected request ID req-1, got %q", got)
+	}
+
+	for _, name := range []string{"db.query", "storage.put"} {
+		child := findSpan(spans, name)
+		if child == nil {
+			t.Errorf("expected a %s span, got %v", name, spans)
+			continue
+		}
+		if child.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("%s: expected the server span as parent", name)
+		}
+	}
+	if query := findSpan(spans, "db.query"); query != nil {
+		if got := attr(query, "db.collection.name").AsString(); got != "widgets" {
+			t.Errorf("expected collection widgets, got %q", got)
+		}
+		if got := attr(query, "db.query.text").AsString(); !strings.Contains(got, `FROM "widgets"`) {
+			t.Errorf("expected the query text, got %q", got)
+		}
+	}
+}
+
+func TestTracingMiddleware_MarksServerErrors(t *testing.T) {
+	exporter := setupExporter(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.TracingMiddleware())
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	server := findSpan(exporter.GetSpans(), "GET /fail")
+	if server == nil {
+		t.Fatal("expected a server span")
+	}
+	if server.Status.Code != codes.Error {
+		t.Errorf("expected an error status, got %v", server.Status)
+	}
+	if server.Parent.IsValid() {
+		t.Error("expected a new trace without traceparent")
+	}
+}
+
+func TestPlugin_SkipsStatementsOutsideTraces(t *testing.T) {
+	exporter := setupExporter(t)
+	db := dryRunDB(t)
+
+	var w widget
+	db.WithContext(context.Background()).First(&w, 1)
+	store := storage.NewLocal(t.TempDir())
+	if _, err := store.Put("a.txt", strings.NewReader("a")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("expected no spans outside a trace, got %v", spans)
+	}
+}
+
+func TestTracingMiddleware_RecordsPanics(t *testing.T) {
+	exporter := setupExporter(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.TracingMiddleware(), middleware.RecoveryMiddleware())
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	server := findSpan(exporter.GetSpans(), "GET /panic")
+	if server == nil {
+		t.Fatal("expected a server span")
+	}
+	if server.Status.Code != codes.Error {
+		t.Errorf("expected an error status, got %v", server.Status)
+	}
+	if len(server.Events) == 0 || server.Events[0].Name != "exception" {
+		t.Errorf("expected the panic recorded as an exception, got %v", server.Events)
+	}
+}